@@ -2,31 +2,58 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
 
+	"github.com/jdashel/posts-api/internal/infra/config"
 	"github.com/jdashel/posts-api/internal/infra/http"
 )
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel() // Ensure cancellation, even for early errors
+	args := os.Args[1:]
 
+	// "config print" shows the effective configuration, taking the same flags as the server
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		cfg := loadConfig(args[2:])
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	cfg := loadConfig(args)
+
+	// The first signal shuts the server down gracefully, a second one kills it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	go func() {
-		ch := make(chan os.Signal, 1)
-		signal.Notify(ch, os.Interrupt)
-		<-ch
-		cancel()
-		fmt.Println("\nShutting down gracefully...")
+		<-ctx.Done()
+		stop()
 	}()
 
-	err := http.StartServer(ctx)
+	err := http.StartServer(ctx, cfg)
 	if err != nil {
-		fmt.Println(err)
+		slog.Error("server stopped with an error", "error", err)
 		os.Exit(1)
 	}
 
-	<-ctx.Done() // Wait for cancellation
-	fmt.Println("Server stopped")
+	slog.Info("server stopped")
+}
+
+// loadConfig loads the configuration or exits, listing every problem found
+func loadConfig(args []string) *config.Config {
+	cfg, err := config.LoadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	return cfg
 }
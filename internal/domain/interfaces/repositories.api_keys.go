@@ -0,0 +1,27 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// APIKeysRepository defines the interface for interacting with API keys
+type APIKeysRepository interface {
+	Create(ctx context.Context, key *models.APIKey) (*models.APIKey, error)
+	// Read retrieves any key by ID, revoked and expired ones included
+	Read(ctx context.Context, id string) (*models.APIKey, error)
+	Find(ctx context.Context, userId string) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, id string, userId string, revokedAt time.Time) error
+	// Touch records the last use of a key
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// APIKeysUseCase represents the use cases for managing personal API keys
+type APIKeysUseCase interface {
+	// CreateAPIKey returns the new key along with its secret, which cannot be retrieved again
+	CreateAPIKey(ctx context.Context, token string, key *models.APIKey) (*models.APIKey, string, error)
+	GetAPIKeys(ctx context.Context, token string) ([]*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, token string, id string) error
+}
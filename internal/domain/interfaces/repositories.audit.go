@@ -0,0 +1,22 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// AuditRepository defines the interface for the append-only audit log
+type AuditRepository interface {
+	Create(ctx context.Context, entry *models.AuditEntry) error
+	Find(ctx context.Context, filter models.AuditFilter, pageNumber int, pageSize int) ([]*models.AuditEntry, error)
+	// Each calls fn for every entry matching the filter, oldest first, stopping at the first error
+	Each(ctx context.Context, filter models.AuditFilter, fn func(entry *models.AuditEntry) error) error
+}
+
+// AuditUseCase represents the use cases for querying the audit log
+type AuditUseCase interface {
+	GetEntries(ctx context.Context, token string, filter models.AuditFilter, pageNumber int, pageSize int) ([]*models.AuditEntry, error)
+	ExportEntries(ctx context.Context, token string, filter models.AuditFilter, w io.Writer) error
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// HealthRepository checks the database the API depends on
+type HealthRepository interface {
+	// Ping checks a connection of the pool answers
+	Ping(ctx context.Context) error
+	// SchemaVersion returns the latest migration applied to the database
+	SchemaVersion(ctx context.Context) (int, error)
+}
+
+// HealthUseCase represents the probes of the orchestrator running the API
+type HealthUseCase interface {
+	// Live tells whether the process is alive, it checks no dependency
+	Live(ctx context.Context) *models.Health
+	// Ready tells whether the API can serve traffic
+	Ready(ctx context.Context) *models.Health
+	// Drain fails readiness from now on, for traffic to move away before shutting down
+	Drain()
+}
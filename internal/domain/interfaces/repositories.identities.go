@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// IdentitiesRepository defines the interface for interacting with linked external identities
+type IdentitiesRepository interface {
+	Create(ctx context.Context, identity *models.Identity) error
+	Read(ctx context.Context, provider string, subject string) (*models.Identity, error)
+}
+
+// OIDCUseCase represents the use cases for signing in with an external identity provider
+type OIDCUseCase interface {
+	// BeginLogin returns the provider URL to send the user to and an opaque flow token
+	// to hand back to CompleteLogin, kept by the client until the provider redirects back
+	BeginLogin(ctx context.Context, provider string) (authURL string, flowToken string, err error)
+	// CompleteLogin exchanges the authorization code and returns an authentication token, or a
+	// two-factor challenge, creating the user on their first login
+	CompleteLogin(ctx context.Context, provider string, flowToken string, state string, code string) (*models.SigninResult, error)
+}
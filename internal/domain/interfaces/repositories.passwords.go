@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// PasswordResetsRepository defines the interface for interacting with password reset tokens
+type PasswordResetsRepository interface {
+	Create(ctx context.Context, reset *models.PasswordReset) error
+	// Consume marks an unused, unexpired token as used and returns its user ID
+	Consume(ctx context.Context, tokenHash string, now time.Time) (string, error)
+	DeleteByUser(ctx context.Context, userId string) error
+}
+
+// PasswordsUseCase represents the use cases for changing and recovering passwords
+type PasswordsUseCase interface {
+	ChangePassword(ctx context.Context, token string, oldPassword string, newPassword string) (string, error)
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, resetToken string, newPassword string) error
+}
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/jdashel/posts-api/internal/domain/models"
 )
@@ -12,7 +13,10 @@ type PostsRepository interface {
 	Read(ctx context.Context, id string, authorId string) (*models.Post, error)
 	Find(ctx context.Context, authorId string, pageNumber int, pageSize int) ([]*models.Post, error)
 	Update(ctx context.Context, id string, authorId string, post *models.Post) (*models.Post, error)
+	FindAll(ctx context.Context, authorId string, pageNumber int, pageSize int) ([]*models.Post, error)
+	SetHidden(ctx context.Context, id string, hiddenAt *time.Time) (*models.Post, error)
 	Delete(ctx context.Context, id string, authorId string) error
+	DeleteAny(ctx context.Context, id string) (*models.Post, error)
 }
 
 // PostsUseCase represents the use cases for posts
@@ -22,4 +26,5 @@ type PostsUseCase interface {
 	GetAllPosts(ctx context.Context, token string, pageNumber int, pageSize int) ([]*models.Post, error)
 	UpdatePost(ctx context.Context, token string, id string, post *models.Post) (*models.Post, error)
 	DeletePost(ctx context.Context, token string, id string) error
+	SetPostHidden(ctx context.Context, token string, id string, hidden bool) (*models.Post, error)
 }
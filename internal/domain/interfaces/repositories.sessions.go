@@ -0,0 +1,32 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// SessionsRepository defines the interface for interacting with sessions
+type SessionsRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	// Read retrieves any session by ID, revoked and expired ones included
+	Read(ctx context.Context, id string) (*models.Session, error)
+	// ReadWithUser retrieves any session by ID along with its user in one query, not found once the user is deleted
+	ReadWithUser(ctx context.Context, id string) (*models.Session, *models.User, error)
+	// Find lists the sessions of a user active at now
+	Find(ctx context.Context, userId string, now time.Time) ([]*models.Session, error)
+	Revoke(ctx context.Context, id string, userId string, revokedAt time.Time) error
+	// RevokeAll revokes every session of a user but exceptId, which may be empty
+	RevokeAll(ctx context.Context, userId string, exceptId string, revokedAt time.Time) error
+	// Touch records the last use of a session
+	Touch(ctx context.Context, id string, seenAt time.Time) error
+}
+
+// SessionsUseCase represents the use cases for managing the sessions of a user
+type SessionsUseCase interface {
+	GetSessions(ctx context.Context, token string) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, token string, id string) error
+	// RevokeOtherSessions logs the user out everywhere but the session of the token
+	RevokeOtherSessions(ctx context.Context, token string) error
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// SigninAttemptsRepository defines the interface for tracking failed signins
+type SigninAttemptsRepository interface {
+	// Find returns the counters of the given keys, keys without failures are omitted
+	Find(ctx context.Context, keys []string) ([]*models.SigninAttempts, error)
+	// Increment atomically counts a failure at now, restarting from one when the last failure is older than since.
+	// It only does so while the counter is still the seen one, nil for none, and returns nil otherwise.
+	Increment(ctx context.Context, key string, seen *models.SigninAttempts, now time.Time, since time.Time) (*models.SigninAttempts, error)
+	// Decrement takes back a failure counted by Increment
+	Decrement(ctx context.Context, key string) error
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,38 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// TwoFactorRepository defines the interface for interacting with TOTP enrolments and recovery codes
+type TwoFactorRepository interface {
+	Read(ctx context.Context, userId string) (*models.TwoFactor, error)
+	// Save creates or replaces a pending enrolment
+	Save(ctx context.Context, twoFactor *models.TwoFactor) error
+	Enable(ctx context.Context, userId string, enabledAt time.Time, step int64) error
+	// UseStep records an accepted time step, failing when it is not newer than the last one
+	UseStep(ctx context.Context, userId string, step int64) error
+	// Delete removes the enrolment and the recovery codes of a user
+	Delete(ctx context.Context, userId string) error
+
+	ReplaceRecoveryCodes(ctx context.Context, userId string, codes []*models.RecoveryCode) error
+	// FindRecoveryCodes lists the unused recovery codes of a user
+	FindRecoveryCodes(ctx context.Context, userId string) ([]*models.RecoveryCode, error)
+	// UseRecoveryCode marks an unused code as used, failing when it already was
+	UseRecoveryCode(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// TwoFactorUseCase represents the use cases for two-factor authentication
+type TwoFactorUseCase interface {
+	// Enroll starts an enrolment, returning the secret to add to an authenticator app
+	Enroll(ctx context.Context, token string) (*models.TwoFactorEnrollment, error)
+	// Enable confirms an enrolment with a first code and returns the recovery codes, shown once
+	Enable(ctx context.Context, token string, code string) ([]string, error)
+	// Disable turns two-factor authentication off, confirmed by the password and a code
+	Disable(ctx context.Context, token string, password string, code string) error
+	// CompleteSignin exchanges a signin challenge token and a TOTP or recovery code for a token
+	CompleteSignin(ctx context.Context, challengeToken string, code string) (string, error)
+}
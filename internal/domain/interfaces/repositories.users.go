@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/jdashel/posts-api/internal/domain/models"
 )
@@ -12,14 +13,41 @@ type UsersRepository interface {
 	Read(ctx context.Context, id string) (*models.User, error)
 	Find(ctx context.Context, email string) (*models.User, error)
 	Update(ctx context.Context, id string, user *models.User) error
-	Delete(ctx context.Context, id string) error
+	Search(ctx context.Context, query string, pageNumber int, pageSize int) ([]*models.User, error)
+	SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error
+	// UpdateRole changes the role of a user, failing with models.ErrInvalidInput when it would leave no active admin
+	UpdateRole(ctx context.Context, id string, role string) error
+	UpdatePassword(ctx context.Context, id string, password string, changedAt time.Time) error
+	// UpdatePasswordHash replaces the hash of an unchanged password, tokens stay valid
+	UpdatePasswordHash(ctx context.Context, id string, password string) error
+	MarkEmailVerified(ctx context.Context, id string, email string, verifiedAt time.Time) error
+	UpdateVerificationSentAt(ctx context.Context, id string, sentAt time.Time) error
+	Delete(ctx context.Context, id string, policy models.DeletionPolicy) ([]string, error)
 }
 
 // UsersUseCase represents the use cases for users
 type UsersUseCase interface {
-	Signup(ctx context.Context, user *models.User) (string, error)
+	Signup(ctx context.Context, email string, password string) (string, error)
 	Signin(ctx context.Context, email string, password string) (string, error)
 	GetProfile(ctx context.Context, token string) (*models.User, error)
-	UpdateProfile(ctx context.Context, token string) error
-	DeleteProfile(ctx context.Context, token string) error
+	UpdateProfile(ctx context.Context, token string, update *models.ProfileUpdate) (*models.User, error)
+	DeleteProfile(ctx context.Context, token string, password string) error
+}
+
+// VerificationUseCase represents the use cases for email verification
+type VerificationUseCase interface {
+	SendVerification(ctx context.Context, user *models.User) error
+	VerifyEmail(ctx context.Context, verificationToken string) error
+	ResendVerification(ctx context.Context, token string) error
+}
+
+// AdminUseCase represents the use cases reserved to administrators
+type AdminUseCase interface {
+	SearchUsers(ctx context.Context, token string, query string, pageNumber int, pageSize int) ([]*models.User, error)
+	GetUser(ctx context.Context, token string, userID string) (*models.User, error)
+	ChangeRole(ctx context.Context, token string, userID string, role string) error
+	SetUserSuspended(ctx context.Context, token string, userID string, suspended bool) error
+	ForcePasswordReset(ctx context.Context, token string, userID string) error
+	GetPosts(ctx context.Context, token string, authorID string, pageNumber int, pageSize int) ([]*models.Post, error)
+	DeletePost(ctx context.Context, token string, id string) error
 }
@@ -0,0 +1,39 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// WebhooksRepository defines the interface for interacting with webhooks and their deliveries
+type WebhooksRepository interface {
+	Create(ctx context.Context, webhook *models.Webhook) (*models.Webhook, error)
+	Read(ctx context.Context, id string, ownerId string) (*models.Webhook, error)
+	Find(ctx context.Context, ownerId string) ([]*models.Webhook, error)
+	FindSubscribed(ctx context.Context, event string, ownerId string) ([]*models.Webhook, error)
+	Update(ctx context.Context, id string, ownerId string, webhook *models.Webhook) (*models.Webhook, error)
+	Delete(ctx context.Context, id string, ownerId string) error
+
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ReadDelivery(ctx context.Context, id string, webhookId string) (*models.WebhookDelivery, error)
+	FindDeliveries(ctx context.Context, webhookId string, pageNumber int, pageSize int) ([]*models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// ClaimDueDeliveries leases pending deliveries due before now until leaseUntil and returns them with their webhook
+	ClaimDueDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error)
+	// RenewDeliveryLease extends the lease of a pending delivery to leaseUntil, as long as it is still leasedUntil.
+	// It tells whether the lease was still held.
+	RenewDeliveryLease(ctx context.Context, id string, leasedUntil time.Time, leaseUntil time.Time) (bool, error)
+}
+
+// WebhooksUseCase represents the use cases for webhooks
+type WebhooksUseCase interface {
+	CreateWebhook(ctx context.Context, token string, webhook *models.Webhook) (*models.Webhook, error)
+	GetWebhooks(ctx context.Context, token string) ([]*models.Webhook, error)
+	GetWebhookById(ctx context.Context, token string, id string) (*models.Webhook, error)
+	UpdateWebhook(ctx context.Context, token string, id string, webhook *models.Webhook) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, token string, id string) error
+	GetDeliveries(ctx context.Context, token string, id string, pageNumber int, pageSize int) ([]*models.WebhookDelivery, error)
+	RedeliverDelivery(ctx context.Context, token string, id string, deliveryId string) (*models.WebhookDelivery, error)
+}
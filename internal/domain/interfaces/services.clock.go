@@ -0,0 +1,8 @@
+package interfaces
+
+import "time"
+
+// ClockService tells the current time, injected so time-dependent logic can be controlled
+type ClockService interface {
+	Now() time.Time
+}
@@ -6,4 +6,7 @@ type HashService interface {
 
 	// ComparePassword compares a plain password with a hashed password
 	ComparePassword(password string, hashedPassword string) bool
+
+	// NeedsRehash tells whether a hashed password uses an outdated algorithm or parameters
+	NeedsRehash(hashedPassword string) bool
 }
@@ -0,0 +1,8 @@
+package interfaces
+
+import "github.com/jdashel/posts-api/internal/domain/models"
+
+type KeysService interface {
+	// JWKS returns the public keys tokens may currently be verified with
+	JWKS() models.JSONWebKeySet
+}
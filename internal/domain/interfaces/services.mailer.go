@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+type MailerService interface {
+	// Send delivers an email
+	Send(ctx context.Context, mail models.Mail) error
+}
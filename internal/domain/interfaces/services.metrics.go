@@ -0,0 +1,15 @@
+package interfaces
+
+import "time"
+
+// MetricsService records the metrics exposed to monitoring
+type MetricsService interface {
+	// ObserveRequest records a handled HTTP request, route being its pattern such as "/posts/:id"
+	ObserveRequest(method string, route string, status int, duration time.Duration)
+
+	// UserSignedUp counts a new account
+	UserSignedUp()
+
+	// PostCreated counts a new post
+	PostCreated()
+}
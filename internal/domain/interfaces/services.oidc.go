@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// IdentityProvider is an OpenID Connect provider users may sign in with
+type IdentityProvider interface {
+	// AuthCodeURL returns the authorization endpoint URL starting an authorization code flow,
+	// with the PKCE challenge derived from the verifier
+	AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error)
+
+	// Exchange trades an authorization code for the verified identity of the user
+	Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*models.ExternalIdentity, error)
+}
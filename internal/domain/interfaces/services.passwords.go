@@ -0,0 +1,21 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// BreachedPasswordsService looks passwords up in a corpus of breached passwords the k-anonymity way,
+// only the start of the password hash is ever sent
+type BreachedPasswordsService interface {
+	// Range returns the upper case SHA-1 suffixes of the breached passwords whose hash starts with
+	// the 5 hexadecimal characters of prefix
+	Range(ctx context.Context, prefix string) ([]string, error)
+}
+
+// PasswordStrengthService estimates how hard passwords are to guess
+type PasswordStrengthService interface {
+	// Estimate scores a password, userInputs are values such as the email it should not be made of
+	Estimate(password string, userInputs ...string) *models.PasswordStrength
+}
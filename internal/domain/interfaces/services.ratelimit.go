@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// RateLimitStore keeps the token buckets of rate limited keys
+type RateLimitStore interface {
+	// Take atomically takes a token from the bucket of key at now
+	Take(ctx context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitResult, error)
+}
@@ -8,4 +8,6 @@ import (
 type SocketService interface {
 	Broadcast(message models.SocketMessage)
 	RequestHandler() gin.HandlerFunc
+	// Running tells whether clients are being served
+	Running() bool
 }
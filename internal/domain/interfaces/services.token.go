@@ -1,11 +1,25 @@
 package interfaces
 
-import "github.com/golang-jwt/jwt"
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
 
 type TokenService interface {
-	// GenerateToken generates a new authentication token for a user ID
-	GenerateToken(userID string) (string, error)
+	// GenerateToken starts a session for a user on the device of the request and returns
+	// a token for it, carrying their role and the given scopes, every scope when nil
+	GenerateToken(ctx context.Context, user *models.User, scopes []string) (string, error)
+
+	// ParseToken parses a token and returns its claims, rejecting tokens issued
+	// before the user's last password change and tokens of revoked sessions
+	ParseToken(ctx context.Context, tokenString string) (jwt.MapClaims, error)
+
+	// GenerateActionToken generates a short-lived token only valid for the given purpose
+	GenerateActionToken(purpose string, claims map[string]any, ttl time.Duration) (string, error)
 
-	// ParseToken parses a token and returns its claims
-	ParseToken(tokenString string) (jwt.MapClaims, error)
+	// ParseActionToken parses a token generated for the given purpose and returns its claims
+	ParseActionToken(purpose string, tokenString string) (jwt.MapClaims, error)
 }
@@ -0,0 +1,14 @@
+package interfaces
+
+import "time"
+
+type TOTPService interface {
+	// GenerateSecret returns a new base32 encoded secret
+	GenerateSecret() (string, error)
+
+	// URI returns the otpauth:// URI authenticator apps enrol from, usually shown as a QR code
+	URI(secret string, account string) string
+
+	// Validate checks a code at now within the tolerated clock skew and returns its time step
+	Validate(secret string, code string, now time.Time) (int64, bool)
+}
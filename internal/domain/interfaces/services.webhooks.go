@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+type WebhookDispatcher interface {
+	// Dispatch queues a delivery of the message to every webhook of its owner subscribed to its type
+	Dispatch(message models.SocketMessage)
+
+	// CheckURL fails with models.ErrInvalidInput when the URL resolves to an address deliveries may not reach
+	CheckURL(ctx context.Context, rawURL string) error
+
+	// Wake asks the dispatcher to look for due deliveries immediately
+	Wake()
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditSignup                 = "user.signup"
+	AuditSignin                 = "user.signin"
+	AuditSigninFailed           = "user.signin_failed"
+	AuditProfileUpdated         = "user.profile_updated"
+	AuditAccountDeleted         = "user.account_deleted"
+	AuditEmailVerified          = "user.email_verified"
+	AuditPasswordChanged        = "user.password_changed"
+	AuditPasswordResetRequested = "user.password_reset_requested"
+	AuditPasswordReset          = "user.password_reset"
+	AuditIdentityLinked         = "user.identity_linked"
+	AuditTwoFactorEnabled       = "user.two_factor_enabled"
+	AuditTwoFactorDisabled      = "user.two_factor_disabled"
+	AuditRecoveryCodeUsed       = "user.recovery_code_used"
+	AuditSessionsRevoked        = "user.sessions_revoked"
+	AuditPostCreated            = "post.created"
+	AuditPostUpdated            = "post.updated"
+	AuditPostDeleted            = "post.deleted"
+	AuditPostHidden             = "post.hidden"
+	AuditPostUnhidden           = "post.unhidden"
+	AuditWebhookCreated         = "webhook.created"
+	AuditWebhookUpdated         = "webhook.updated"
+	AuditWebhookDeleted         = "webhook.deleted"
+	AuditAPIKeyCreated          = "api_key.created"
+	AuditAPIKeyRevoked          = "api_key.revoked"
+	AuditSessionRevoked         = "session.revoked"
+	AuditAdminRoleChanged       = "admin.role_changed"
+	AuditAdminUserSuspended     = "admin.user_suspended"
+	AuditAdminUserUnsuspended   = "admin.user_unsuspended"
+	AuditAdminPasswordReset     = "admin.password_reset"
+	AuditAdminPostDeleted       = "admin.post_deleted"
+)
+
+// Audited target types
+const (
+	AuditTargetUser    = "user"
+	AuditTargetPost    = "post"
+	AuditTargetWebhook = "webhook"
+	AuditTargetAPIKey  = "api_key"
+	AuditTargetSession = "session"
+)
+
+// AuditEntry is an append-only record of who did what to which target
+type AuditEntry struct {
+	ID         string          `json:"id"`
+	ActorID    string          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows audit queries, empty fields match everything
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+}
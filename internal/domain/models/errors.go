@@ -0,0 +1,31 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Sentinel errors shared by the use cases, wrapped with context where they are returned
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidInput    = errors.New("invalid input")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrUnavailable     = errors.New("service unavailable")
+)
+
+// RetryError is a rate limiting error telling when the request may be retried
+type RetryError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Message
+}
+
+// Unwrap makes RetryError match ErrTooManyRequests
+func (e *RetryError) Unwrap() error {
+	return ErrTooManyRequests
+}
@@ -0,0 +1,25 @@
+package models
+
+// Health check statuses
+const (
+	HealthPass = "pass"
+	HealthFail = "fail"
+)
+
+// Health reports whether the API is alive or ready, with the result of each check
+type Health struct {
+	Status string                  `json:"status"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the result of checking one dependency
+type HealthCheck struct {
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Passed tells whether every check passed
+func (h *Health) Passed() bool {
+	return h.Status == HealthPass
+}
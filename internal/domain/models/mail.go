@@ -0,0 +1,8 @@
+package models
+
+// Mail represents an outgoing email
+type Mail struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
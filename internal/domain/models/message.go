@@ -1,6 +1,116 @@
 package models
 
+import "time"
+
+// Event types carried by SocketMessage.Type
+const (
+	EventPostCreated = "post_created"
+	EventPostUpdated = "post_updated"
+	EventPostDeleted = "post_deleted"
+	EventUserUpdated = "user_updated"
+	EventUserDeleted = "user_deleted"
+)
+
+// EventSchemaVersion is the version of the payload schemas below, bumped on breaking changes
+const EventSchemaVersion = 1
+
+// EventTypes lists every event type that can be broadcast
+var EventTypes = []string{
+	EventPostCreated,
+	EventPostUpdated,
+	EventPostDeleted,
+	EventUserUpdated,
+	EventUserDeleted,
+}
+
+// SocketMessage is the envelope of every broadcast event. Payload schemas per type:
+//
+//	post_created, post_updated: Post
+//	post_deleted:               PostDeletedPayload
+//	user_updated:               UserUpdatedPayload
+//	user_deleted:               UserDeletedPayload
 type SocketMessage struct {
-	Type    string `json:"type"`
-	Payload any    `json:"payload"`
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Payload    any       `json:"payload"`
+}
+
+// PostDeletedPayload identifies a deleted post
+type PostDeletedPayload struct {
+	ID       string `json:"id"`
+	AuthorID string `json:"author_id"`
+}
+
+// UserUpdatedPayload identifies a user whose profile changed
+type UserUpdatedPayload struct {
+	UserID    string    `json:"user_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserDeletedPayload identifies a deleted user account
+type UserDeletedPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// OwnerID returns the user the event belongs to, the author of a post or the user themselves.
+// Webhooks only receive the events of their owner.
+func (m SocketMessage) OwnerID() string {
+	switch payload := m.Payload.(type) {
+	case *Post:
+		return payload.AuthorID
+	case PostDeletedPayload:
+		return payload.AuthorID
+	case UserUpdatedPayload:
+		return payload.UserID
+	case UserDeletedPayload:
+		return payload.UserID
+	}
+	return ""
+}
+
+// Public tells whether anyone may see the event, the events of visible posts. Account events and
+// those of hidden posts only go to their owner's webhooks.
+func (m SocketMessage) Public() bool {
+	switch payload := m.Payload.(type) {
+	case *Post:
+		return payload.HiddenAt == nil
+	case PostDeletedPayload:
+		return true
+	}
+	return false
+}
+
+func newSocketMessage(eventType string, payload any, occurredAt time.Time) SocketMessage {
+	return SocketMessage{
+		Type:       eventType,
+		Version:    EventSchemaVersion,
+		OccurredAt: occurredAt,
+		Payload:    payload,
+	}
+}
+
+// NewPostCreatedMessage builds a post_created event
+func NewPostCreatedMessage(post *Post, occurredAt time.Time) SocketMessage {
+	return newSocketMessage(EventPostCreated, post, occurredAt)
+}
+
+// NewPostUpdatedMessage builds a post_updated event
+func NewPostUpdatedMessage(post *Post, occurredAt time.Time) SocketMessage {
+	return newSocketMessage(EventPostUpdated, post, occurredAt)
+}
+
+// NewPostDeletedMessage builds a post_deleted event
+func NewPostDeletedMessage(id string, authorID string, occurredAt time.Time) SocketMessage {
+	return newSocketMessage(EventPostDeleted, PostDeletedPayload{ID: id, AuthorID: authorID}, occurredAt)
+}
+
+// NewUserUpdatedMessage builds a user_updated event
+func NewUserUpdatedMessage(userID string, occurredAt time.Time) SocketMessage {
+	return newSocketMessage(EventUserUpdated, UserUpdatedPayload{UserID: userID, UpdatedAt: occurredAt}, occurredAt)
+}
+
+// NewUserDeletedMessage builds a user_deleted event
+func NewUserDeletedMessage(userID string, occurredAt time.Time) SocketMessage {
+	return newSocketMessage(EventUserDeleted, UserDeletedPayload{UserID: userID}, occurredAt)
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PasswordReset represents a single-use password reset token, only its hash is stored
+type PasswordReset struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// PasswordStrength estimates how hard a password is to guess, scored from 0, guessed at once,
+// to 4, out of reach of an offline attack, with advice on making it stronger
+type PasswordStrength struct {
+	Score    int      `json:"score"`
+	Feedback []string `json:"feedback"`
+}
@@ -0,0 +1,42 @@
+package models
+
+// Roles a user can hold
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every valid role
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// Permissions granted by roles beyond managing one's own content
+const (
+	PermissionModeratePosts = "posts:moderate"
+	PermissionManageUsers   = "users:manage"
+)
+
+// RolePermissions maps each role to the permissions it grants
+var RolePermissions = map[string][]string{
+	RoleUser:      {},
+	RoleModerator: {PermissionModeratePosts},
+	RoleAdmin:     {PermissionModeratePosts, PermissionManageUsers},
+}
+
+// HasPermission reports whether the role grants the permission
+func HasPermission(role string, permission string) bool {
+	for _, granted := range RolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Actions checked by the account policy before a use case proceeds
+const (
+	ActionCreatePost     = "posts:create"
+	ActionUpdatePost     = "posts:update"
+	ActionDeletePost     = "posts:delete"
+	ActionManageWebhooks = "webhooks:manage"
+)
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit allows bursts of Requests, refilled at Requests per Period
+type RateLimit struct {
+	Requests int           `json:"requests"`
+	Period   time.Duration `json:"period"`
+}
+
+// RateLimitResult tells whether a request was allowed and the state of its bucket
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until a request is allowed again, zero when allowed
+	RetryAfter time.Duration
+}
+
+// TokenBucket is the state of one rate limited key
+type TokenBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Take refills the bucket up to now and takes a token from it if one is available
+func (b *TokenBucket) Take(limit RateLimit, now time.Time) RateLimitResult {
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Period.Seconds() // Tokens per second
+
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+elapsed*rate)
+	}
+	b.UpdatedAt = now
+
+	result := RateLimitResult{Limit: limit.Requests}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.Tokens) / rate)
+	}
+	result.Remaining = int(b.Tokens)
+	result.Reset = seconds((capacity - b.Tokens) / rate)
+
+	return result
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
@@ -0,0 +1,57 @@
+package models
+
+import "context"
+
+// RequestInfo describes the client behind a request, carried in its context
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of ctx carrying the request info
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request info carried by ctx, if any
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the ID correlating the logs of a request
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type tokenClaimsKey struct{}
+
+type tokenClaims struct {
+	token  string
+	claims map[string]any
+}
+
+// WithTokenClaims returns a copy of ctx carrying the claims of an already verified token,
+// so it is not verified again for the same request
+func WithTokenClaims(ctx context.Context, token string, claims map[string]any) context.Context {
+	return context.WithValue(ctx, tokenClaimsKey{}, tokenClaims{token, claims})
+}
+
+// TokenClaimsFromContext returns the claims carried by ctx for token, if any
+func TokenClaimsFromContext(ctx context.Context, token string) (map[string]any, bool) {
+	cached, ok := ctx.Value(tokenClaimsKey{}).(tokenClaims)
+	if !ok || cached.token != token {
+		return nil, false
+	}
+	return cached.claims, true
+}
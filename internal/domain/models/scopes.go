@@ -0,0 +1,32 @@
+package models
+
+// Token scopes, limiting what a token or an API key may do on behalf of its user
+const (
+	ScopePostsRead      = "posts:read"
+	ScopePostsWrite     = "posts:write"
+	ScopeProfileRead    = "profile:read"
+	ScopeProfileWrite   = "profile:write"
+	ScopeWebhooksManage = "webhooks:manage"
+	ScopeAPIKeysManage  = "api_keys:manage"
+	ScopeAdmin          = "admin"
+)
+
+// Scopes lists every known scope, tokens carry them all unless narrowed
+var Scopes = []string{ScopePostsRead, ScopePostsWrite, ScopeProfileRead, ScopeProfileWrite, ScopeWebhooksManage,
+	ScopeAPIKeysManage, ScopeAdmin}
+
+// ClaimScopes returns the scopes of parsed token claims, none when the claims carry no scopes
+func ClaimScopes(claims map[string]any) []string {
+	values, ok := claims["scopes"].([]any)
+	if !ok {
+		return []string{}
+	}
+
+	scopes := make([]string, 0, len(values))
+	for _, value := range values {
+		if scope, ok := value.(string); ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
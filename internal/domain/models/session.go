@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// SessionTTL is how long a signin lasts, tokens expire along with their session
+const SessionTTL = 24 * time.Hour
+
+// Session is a signin on a device, every token carries the ID of its session
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Current tells whether the session is the one of the request listing it
+	Current bool `json:"current"`
+}
+
+// Active tells whether the session is neither revoked nor expired at now
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// SigninAttempts counts the recent failed signins of an account or a client IP
+type SigninAttempts struct {
+	Key           string    `json:"key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// TwoFactor is the TOTP enrolment of a user, pending until EnabledAt is set
+type TwoFactor struct {
+	UserID    string     `json:"user_id"`
+	Secret    string     `json:"-"`
+	EnabledAt *time.Time `json:"enabled_at"`
+	// LastUsedStep is the time step of the last accepted code, codes cannot be replayed
+	LastUsedStep int64     `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Enabled tells whether signins require a second factor
+func (t *TwoFactor) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TwoFactorEnrollment is what an authenticator app needs to generate codes
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCode is a one-time code replacing a TOTP code, only its hash is stored
+type RecoveryCode struct {
+	ID       string     `json:"id"`
+	UserID   string     `json:"user_id"`
+	CodeHash string     `json:"-"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+// SigninResult is either a token or, when two-factor authentication is on, a challenge
+// token to exchange for one along with a code
+type SigninResult struct {
+	Token             string `json:"token,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
@@ -1,13 +1,59 @@
 package models
 
-import "time"
+import (
+	"log/slog"
+	"time"
+)
 
 // User represents a user entity
 type User struct {
-	ID        string     `json:"id"`
-	Email     string     `json:"email"`
-	Password  string     `json:"-"` // Omit password from JSON responses
+	ID          string `json:"id"`
+	Email       string `json:"email"`
+	Password    string `json:"-"` // Omit password from JSON responses
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	Role        string `json:"role"`
+
+	EmailVerifiedAt    *time.Time `json:"email_verified_at"`
+	VerificationSentAt *time.Time `json:"-"`
+	PasswordChangedAt  *time.Time `json:"-"`
+	SuspendedAt        *time.Time `json:"suspended_at"`
+
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at"`
 }
+
+// LogValue keeps the password hash and personal details of a user out of logs
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("id", u.ID), slog.String("role", u.Role))
+}
+
+// ProfileUpdate holds the profile fields to change, nil fields are left untouched
+type ProfileUpdate struct {
+	Email       *string `json:"email"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+// DeletionPolicy decides what happens to a user's posts when the account is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete removes the account and its posts
+	DeletionPolicyDelete DeletionPolicy = "delete"
+	// DeletionPolicySoftDelete marks the account and its posts as deleted, keeping the rows
+	DeletionPolicySoftDelete DeletionPolicy = "soft_delete"
+)
+
+// Suspended reports whether an admin suspended the account
+func (u *User) Suspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// EmailVerified reports whether the user confirmed their current email address
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
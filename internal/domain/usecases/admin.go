@@ -0,0 +1,195 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+type AdminUseCase struct {
+	usersRepository interfaces.UsersRepository
+	postsRepository interfaces.PostsRepository
+	hashService     interfaces.HashService
+	tokenService    interfaces.TokenService
+	socketService   interfaces.SocketService
+	clockService    interfaces.ClockService
+	passwords       interfaces.PasswordsUseCase
+	policy          *Policy
+	auditor         *Auditor
+	sessions        interfaces.SessionsRepository
+}
+
+// Admin usecases constructor
+func NewAdminUseCase(
+	usersRepository interfaces.UsersRepository,
+	postsRepository interfaces.PostsRepository,
+	hashService interfaces.HashService,
+	tokenService interfaces.TokenService,
+	socketService interfaces.SocketService,
+	clockService interfaces.ClockService,
+	passwords interfaces.PasswordsUseCase,
+	policy *Policy,
+	auditor *Auditor,
+	sessions interfaces.SessionsRepository,
+) *AdminUseCase {
+	return &AdminUseCase{usersRepository, postsRepository, hashService, tokenService, socketService, clockService, passwords, policy,
+		auditor, sessions}
+}
+
+// SearchUsers finds users by email or display name with pagination
+func (uc *AdminUseCase) SearchUsers(ctx context.Context, token string, query string, pageNumber int, pageSize int) ([]*models.User, error) {
+	if _, err := uc.authorize(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return uc.usersRepository.Search(ctx, query, pageNumber, pageSize)
+}
+
+// GetUser retrieves any user by ID
+func (uc *AdminUseCase) GetUser(ctx context.Context, token string, userID string) (*models.User, error) {
+	if _, err := uc.authorize(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return uc.usersRepository.Read(ctx, userID)
+}
+
+// SetUserSuspended suspends a user, locking them out and revoking their tokens, or lifts the suspension
+func (uc *AdminUseCase) SetUserSuspended(ctx context.Context, token string, userID string, suspended bool) error {
+	admin, err := uc.authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if admin.ID == userID {
+		return fmt.Errorf("%w: admins cannot suspend themselves", models.ErrInvalidInput)
+	}
+
+	var suspendedAt *time.Time
+	if suspended {
+		now := uc.clockService.Now()
+		suspendedAt = &now
+	}
+	if err := uc.usersRepository.SetSuspended(ctx, userID, suspendedAt); err != nil {
+		return err
+	}
+	action := models.AuditAdminUserUnsuspended
+	if suspended {
+		action = models.AuditAdminUserSuspended
+	}
+	uc.auditor.Record(ctx, admin.ID, action, models.AuditTargetUser, userID, nil, nil)
+
+	uc.socketService.Broadcast(models.NewUserUpdatedMessage(userID, uc.clockService.Now()))
+
+	return nil
+}
+
+// ForcePasswordReset invalidates a user's password and tokens and emails them a reset link
+func (uc *AdminUseCase) ForcePasswordReset(ctx context.Context, token string, userID string) error {
+	admin, err := uc.authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	user, err := uc.usersRepository.Read(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Replace the password with a random one nobody knows
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	hashedPassword, err := uc.hashService.HashPassword(hex.EncodeToString(secret))
+	if err != nil {
+		return err
+	}
+	now := uc.clockService.Now()
+	if err := uc.usersRepository.UpdatePassword(ctx, user.ID, hashedPassword, now); err != nil {
+		return err
+	}
+	if err := uc.sessions.RevokeAll(ctx, user.ID, "", now); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, admin.ID, models.AuditAdminPasswordReset, models.AuditTargetUser, user.ID, nil, nil)
+
+	return uc.passwords.ForgotPassword(ctx, user.Email)
+}
+
+// GetPosts lists the posts of every author, or of one when authorID is set, with pagination
+func (uc *AdminUseCase) GetPosts(ctx context.Context, token string, authorID string, pageNumber int, pageSize int) ([]*models.Post, error) {
+	if _, err := uc.authorize(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return uc.postsRepository.FindAll(ctx, authorID, pageNumber, pageSize)
+}
+
+// DeletePost permanently deletes any post
+func (uc *AdminUseCase) DeletePost(ctx context.Context, token string, id string) error {
+	admin, err := uc.authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	post, err := uc.postsRepository.DeleteAny(ctx, id)
+	if err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, admin.ID, models.AuditAdminPostDeleted, models.AuditTargetPost, post.ID, post, nil)
+
+	uc.socketService.Broadcast(models.NewPostDeletedMessage(post.ID, post.AuthorID, uc.clockService.Now()))
+
+	return nil
+}
+
+// ChangeRole assigns a role to a user, other than the admin themselves, keeping at least one active admin
+func (uc *AdminUseCase) ChangeRole(ctx context.Context, token string, userID string, role string) error {
+	admin, err := uc.authorize(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(models.Roles, role) {
+		return fmt.Errorf("%w: unknown role %q", models.ErrInvalidInput, role)
+	}
+	if admin.ID == userID {
+		return fmt.Errorf("%w: admins cannot change their own role", models.ErrInvalidInput)
+	}
+
+	user, err := uc.usersRepository.Read(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := uc.usersRepository.UpdateRole(ctx, userID, role); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, admin.ID, models.AuditAdminRoleChanged, models.AuditTargetUser, userID,
+		map[string]string{"role": user.Role}, map[string]string{"role": role})
+
+	uc.socketService.Broadcast(models.NewUserUpdatedMessage(userID, uc.clockService.Now()))
+
+	return nil
+}
+
+// authorize checks the token belongs to a user allowed to manage users and returns them
+func (uc *AdminUseCase) authorize(ctx context.Context, token string) (*models.User, error) {
+	claims, err := uc.tokenService.ParseToken(ctx, token)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, errors.New("invalid user ID in token")
+	}
+
+	return uc.policy.Require(ctx, userID, models.PermissionManageUsers)
+}
@@ -0,0 +1,150 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+type APIKeysUseCase struct {
+	repository   interfaces.APIKeysRepository
+	tokenService interfaces.TokenService
+	uuidService  interfaces.UUIDService
+	clockService interfaces.ClockService
+	auditor      *Auditor
+}
+
+// API keys usecases constructor
+func NewAPIKeysUseCase(
+	repository interfaces.APIKeysRepository,
+	tokenService interfaces.TokenService,
+	uuidService interfaces.UUIDService,
+	clockService interfaces.ClockService,
+	auditor *Auditor,
+) *APIKeysUseCase {
+	return &APIKeysUseCase{repository, tokenService, uuidService, clockService, auditor}
+}
+
+// CreateAPIKey creates a key for the token's user and returns it with the full key,
+// "pak_<id>_<secret>", which is only shown this once
+func (uc *APIKeysUseCase) CreateAPIKey(ctx context.Context, token string, key *models.APIKey) (*models.APIKey, string, error) {
+	claims, err := uc.claims(ctx, token)
+	if err != nil {
+		return nil, "", err
+	}
+	userID := claims["user_id"].(string)
+
+	if err := uc.validateAPIKey(key); err != nil {
+		return nil, "", err
+	}
+	// A key cannot grant more than the token creating it
+	granted := models.ClaimScopes(claims)
+	for _, scope := range key.Scopes {
+		if !slices.Contains(granted, scope) {
+			return nil, "", fmt.Errorf("%w: token lacks scope %q", models.ErrForbidden, scope)
+		}
+	}
+
+	id, err := uc.uuidService.GenerateID(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomString()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key.ID = id
+	key.UserID = userID
+	key.SecretHash = models.HashAPIKeySecret(secret)
+	key.CreatedAt = uc.clockService.Now()
+	if key.ExpiresAt != nil {
+		expiresAt := key.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+
+	created, err := uc.repository.Create(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	uc.auditor.Record(ctx, userID, models.AuditAPIKeyCreated, models.AuditTargetAPIKey, created.ID, nil, created)
+
+	return created, models.APIKeyPrefix + created.ID + "_" + secret, nil
+}
+
+// GetAPIKeys lists the keys of the token's user, revoked and expired ones included
+func (uc *APIKeysUseCase) GetAPIKeys(ctx context.Context, token string) ([]*models.APIKey, error) {
+	userID, err := uc.userID(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.repository.Find(ctx, userID)
+}
+
+// RevokeAPIKey disables a key of the token's user for good
+func (uc *APIKeysUseCase) RevokeAPIKey(ctx context.Context, token string, id string) error {
+	userID, err := uc.userID(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.repository.Revoke(ctx, id, userID, uc.clockService.Now()); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, userID, models.AuditAPIKeyRevoked, models.AuditTargetAPIKey, id, nil, nil)
+
+	return nil
+}
+
+// userID returns the user of the token
+func (uc *APIKeysUseCase) userID(ctx context.Context, token string) (string, error) {
+	claims, err := uc.claims(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	return claims["user_id"].(string), nil
+}
+
+// claims returns the claims of the token, API keys cannot be used to manage API keys
+func (uc *APIKeysUseCase) claims(ctx context.Context, token string) (map[string]any, error) {
+	claims, err := uc.tokenService.ParseToken(ctx, token)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	if _, ok := claims["api_key_id"]; ok {
+		return nil, fmt.Errorf("%w: API keys cannot manage API keys", models.ErrForbidden)
+	}
+	if _, ok := claims["user_id"].(string); !ok {
+		return nil, errors.New("invalid user ID in token")
+	}
+	return claims, nil
+}
+
+func (uc *APIKeysUseCase) validateAPIKey(key *models.APIKey) error {
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" || utf8.RuneCountInString(key.Name) > 64 {
+		return fmt.Errorf("%w: name must be between 1 and 64 characters", models.ErrInvalidInput)
+	}
+
+	if len(key.Scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", models.ErrInvalidInput)
+	}
+	if err := validateScopes(key.Scopes); err != nil {
+		return err
+	}
+	slices.Sort(key.Scopes)
+	key.Scopes = slices.Compact(key.Scopes)
+
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(uc.clockService.Now()) {
+		return fmt.Errorf("%w: expiry must be in the future", models.ErrInvalidInput)
+	}
+
+	return nil
+}
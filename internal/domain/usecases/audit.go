@@ -0,0 +1,113 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// Auditor appends entries to the audit log on behalf of the other use cases
+type Auditor struct {
+	repository   interfaces.AuditRepository
+	uuidService  interfaces.UUIDService
+	clockService interfaces.ClockService
+	logger       *slog.Logger
+}
+
+// Auditor constructor
+func NewAuditor(repository interfaces.AuditRepository, uuidService interfaces.UUIDService, clockService interfaces.ClockService,
+	logger *slog.Logger) *Auditor {
+	return &Auditor{repository, uuidService, clockService, logger.With("component", "audit")}
+}
+
+// Record appends an entry, taking the client IP and user agent from the request context.
+// before and after are snapshots of the target and may be nil. A failure to audit is
+// logged rather than failing the action that was already performed.
+func (a *Auditor) Record(ctx context.Context, actorID string, action string, targetType string, targetID string, before any, after any) {
+	id, err := a.uuidService.GenerateID(ctx)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "failed to generate id", "action", action, "error", err)
+		return
+	}
+
+	info := models.RequestInfoFromContext(ctx)
+	entry := &models.AuditEntry{
+		ID:         id,
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         info.IP,
+		UserAgent:  info.UserAgent,
+		Before:     snapshot(before),
+		After:      snapshot(after),
+		CreatedAt:  a.clockService.Now(),
+	}
+
+	// The action already happened, record it even if the request is being cancelled
+	if err := a.repository.Create(context.WithoutCancel(ctx), entry); err != nil {
+		a.logger.ErrorContext(ctx, "failed to record entry", "action", action, "target_id", targetID, "error", err)
+	}
+}
+
+func snapshot(value any) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+type AuditUseCase struct {
+	repository   interfaces.AuditRepository
+	tokenService interfaces.TokenService
+	policy       *Policy
+}
+
+// Audit usecases constructor
+func NewAuditUseCase(repository interfaces.AuditRepository, tokenService interfaces.TokenService, policy *Policy) *AuditUseCase {
+	return &AuditUseCase{repository, tokenService, policy}
+}
+
+// GetEntries retrieves audit entries matching the filter with pagination, newest first
+func (uc *AuditUseCase) GetEntries(ctx context.Context, token string, filter models.AuditFilter, pageNumber int, pageSize int) ([]*models.AuditEntry, error) {
+	if err := uc.authorize(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return uc.repository.Find(ctx, filter, pageNumber, pageSize)
+}
+
+// ExportEntries writes every audit entry matching the filter to w as JSON Lines, oldest first
+func (uc *AuditUseCase) ExportEntries(ctx context.Context, token string, filter models.AuditFilter, w io.Writer) error {
+	if err := uc.authorize(ctx, token); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	return uc.repository.Each(ctx, filter, func(entry *models.AuditEntry) error {
+		return encoder.Encode(entry)
+	})
+}
+
+func (uc *AuditUseCase) authorize(ctx context.Context, token string) error {
+	claims, err := uc.tokenService.ParseToken(ctx, token)
+	if err != nil {
+		return errors.New("invalid token")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return errors.New("invalid user ID in token")
+	}
+
+	_, err = uc.policy.Require(ctx, userID, models.PermissionManageUsers)
+	return err
+}
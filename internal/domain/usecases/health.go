@@ -0,0 +1,113 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// HealthUseCase answers the liveness and readiness probes. Readiness runs every check
+// concurrently, each given at most timeout, so one hanging dependency cannot stall the probe.
+type HealthUseCase struct {
+	repository    interfaces.HealthRepository
+	socketService interfaces.SocketService
+	schemaVersion int
+	timeout       time.Duration
+	draining      atomic.Bool
+}
+
+// Health usecases constructor
+func NewHealthUseCase(repository interfaces.HealthRepository, socketService interfaces.SocketService, schemaVersion int,
+	timeout time.Duration) *HealthUseCase {
+	return &HealthUseCase{repository: repository, socketService: socketService, schemaVersion: schemaVersion, timeout: timeout}
+}
+
+// Live passes as long as the process can answer
+func (uc *HealthUseCase) Live(ctx context.Context) *models.Health {
+	return &models.Health{Status: models.HealthPass}
+}
+
+// Ready checks the database answers and has the expected schema, and the websocket hub runs
+func (uc *HealthUseCase) Ready(ctx context.Context) *models.Health {
+	checks := map[string]func(ctx context.Context) error{
+		"database": uc.repository.Ping,
+		"migrations": func(ctx context.Context) error {
+			version, err := uc.repository.SchemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if version < uc.schemaVersion {
+				return fmt.Errorf("schema version is %d, %d expected", version, uc.schemaVersion)
+			}
+			return nil
+		},
+		"websocket_hub": func(ctx context.Context) error {
+			if !uc.socketService.Running() {
+				return errors.New("hub is not running")
+			}
+			return nil
+		},
+		"shutdown": func(ctx context.Context) error {
+			if uc.draining.Load() {
+				return errors.New("shutting down")
+			}
+			return nil
+		},
+	}
+
+	health := &models.Health{Status: models.HealthPass, Checks: map[string]*models.HealthCheck{}}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			result := uc.run(ctx, check)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			health.Checks[name] = result
+			if result.Status != models.HealthPass {
+				health.Status = models.HealthFail
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return health
+}
+
+// Drain fails readiness from now on
+func (uc *HealthUseCase) Drain() {
+	uc.draining.Store(true)
+}
+
+// run runs a check within the timeout, a check still running when it expires fails
+func (uc *HealthUseCase) run(ctx context.Context, check func(ctx context.Context) error) *models.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, uc.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", uc.timeout)
+	}
+
+	result := &models.HealthCheck{Status: models.HealthPass, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = models.HealthFail
+		result.Error = err.Error()
+	}
+	return result
+}
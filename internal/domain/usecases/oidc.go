@@ -0,0 +1,229 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+const (
+	oidcFlowPurpose = "oidc_flow"
+	oidcFlowTTL     = 10 * time.Minute
+)
+
+type OIDCUseCase struct {
+	usersRepository      interfaces.UsersRepository
+	identitiesRepository interfaces.IdentitiesRepository
+	providers            map[string]interfaces.IdentityProvider
+	hashService          interfaces.HashService
+	tokenService         interfaces.TokenService
+	uuidService          interfaces.UUIDService
+	clockService         interfaces.ClockService
+	auditor              *Auditor
+	twoFactor            interfaces.TwoFactorRepository
+}
+
+// OIDC usecases constructor, providers are keyed by the name used in their routes
+func NewOIDCUseCase(
+	usersRepository interfaces.UsersRepository,
+	identitiesRepository interfaces.IdentitiesRepository,
+	providers map[string]interfaces.IdentityProvider,
+	hashService interfaces.HashService,
+	tokenService interfaces.TokenService,
+	uuidService interfaces.UUIDService,
+	clockService interfaces.ClockService,
+	auditor *Auditor,
+	twoFactor interfaces.TwoFactorRepository,
+) *OIDCUseCase {
+	return &OIDCUseCase{usersRepository, identitiesRepository, providers, hashService, tokenService, uuidService, clockService, auditor,
+		twoFactor}
+}
+
+// BeginLogin starts an authorization code flow with PKCE. The state, nonce and code verifier
+// travel in a signed flow token so no server-side storage is needed.
+func (uc *OIDCUseCase) BeginLogin(ctx context.Context, provider string) (string, string, error) {
+	identityProvider, ok := uc.providers[provider]
+	if !ok {
+		return "", "", fmt.Errorf("identity provider %w", models.ErrNotFound)
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	flowToken, err := uc.tokenService.GenerateActionToken(oidcFlowPurpose, map[string]any{
+		"provider": provider,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+	}, oidcFlowTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := identityProvider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, flowToken, nil
+}
+
+// CompleteLogin finishes the flow started by BeginLogin and signs the user in. A new identity
+// is linked to the user with the same email when both the provider and the user verified it,
+// otherwise a user is created. Users with two-factor authentication on get a challenge token
+// to exchange at the second step of signin, like with a password.
+func (uc *OIDCUseCase) CompleteLogin(ctx context.Context, provider string, flowToken string, state string,
+	code string) (*models.SigninResult, error) {
+	identityProvider, ok := uc.providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("identity provider %w", models.ErrNotFound)
+	}
+
+	claims, err := uc.tokenService.ParseActionToken(oidcFlowPurpose, flowToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid or expired login flow", models.ErrUnauthorized)
+	}
+	if claims["provider"] != provider || claims["state"] != state || state == "" {
+		return nil, fmt.Errorf("%w: login flow does not match", models.ErrUnauthorized)
+	}
+	verifier, _ := claims["verifier"].(string)
+	nonce, _ := claims["nonce"].(string)
+
+	external, err := identityProvider.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", models.ErrUnauthorized, err)
+	}
+	external.Provider = provider
+
+	user, err := uc.userFor(ctx, external)
+	if err != nil {
+		return nil, err
+	}
+	if user.Suspended() {
+		return nil, fmt.Errorf("%w: account is suspended", models.ErrForbidden)
+	}
+
+	twoFactor, err := uc.twoFactor.Read(ctx, user.ID)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil && twoFactor.Enabled() {
+		challenge, err := twoFactorChallenge(uc.tokenService, user, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &models.SigninResult{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	token, err := uc.tokenService.GenerateToken(ctx, user, nil)
+	if err != nil {
+		return nil, err
+	}
+	uc.auditor.Record(ctx, user.ID, models.AuditSignin, models.AuditTargetUser, user.ID, nil, map[string]string{"provider": provider})
+
+	return &models.SigninResult{Token: token}, nil
+}
+
+// userFor returns the user linked to the external identity, linking or creating one on first login
+func (uc *OIDCUseCase) userFor(ctx context.Context, external *models.ExternalIdentity) (*models.User, error) {
+	identity, err := uc.identitiesRepository.Read(ctx, external.Provider, external.Subject)
+	if err == nil {
+		return uc.usersRepository.Read(ctx, identity.UserID)
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(external.Email))
+	if email == "" {
+		return nil, fmt.Errorf("%w: the identity provider did not share an email address", models.ErrInvalidInput)
+	}
+
+	user, err := uc.usersRepository.Find(ctx, email)
+	if err == nil {
+		// Linking to an unverified account would hand it to whoever registered the address first
+		if !external.EmailVerified || !user.EmailVerified() {
+			return nil, fmt.Errorf("%w: an account already uses this email, sign in with your password first", models.ErrForbidden)
+		}
+	} else if user, err = uc.createUser(ctx, email, external); err != nil {
+		return nil, err
+	}
+
+	identity = &models.Identity{
+		Provider:  external.Provider,
+		Subject:   external.Subject,
+		UserID:    user.ID,
+		Email:     email,
+		CreatedAt: uc.clockService.Now(),
+	}
+	if err := uc.identitiesRepository.Create(ctx, identity); err != nil {
+		return nil, err
+	}
+	uc.auditor.Record(ctx, user.ID, models.AuditIdentityLinked, models.AuditTargetUser, user.ID, nil, identity)
+
+	return user, nil
+}
+
+// createUser creates the user of a first login, with a random password they can reset to sign in with one
+func (uc *OIDCUseCase) createUser(ctx context.Context, email string, external *models.ExternalIdentity) (*models.User, error) {
+	password, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := uc.hashService.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uc.uuidService.GenerateID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := external.Name
+	if utf8.RuneCountInString(displayName) > 64 {
+		displayName = string([]rune(displayName)[:64])
+	}
+
+	user, err := uc.usersRepository.Create(ctx, &models.User{ID: id, Email: email, Password: hashedPassword, DisplayName: displayName})
+	if err != nil {
+		return nil, err
+	}
+	uc.auditor.Record(ctx, user.ID, models.AuditSignup, models.AuditTargetUser, user.ID, nil, user)
+
+	if external.EmailVerified {
+		now := uc.clockService.Now()
+		if err := uc.usersRepository.MarkEmailVerified(ctx, user.ID, email, now); err != nil {
+			return nil, err
+		}
+		user.EmailVerifiedAt = &now
+	}
+
+	return user, nil
+}
+
+// randomString returns 32 random bytes encoded as unpadded base64url, suitable as a PKCE verifier
+func randomString() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,417 @@
+package usecases
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/services"
+)
+
+const (
+	mockClientID     = "posts-api"
+	mockClientSecret = "client-secret"
+	mockRedirectURL  = "https://posts.example.com/auth/mock/callback"
+)
+
+// mockProvider is an OpenID Connect provider issuing ID tokens for a single user. It enforces
+// PKCE at its token endpoint, and echoes the nonce of the authorization request unless
+// forgedNonce is set.
+type mockProvider struct {
+	*httptest.Server
+	t       *testing.T
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+
+	mu          sync.Mutex
+	grants      map[string]url.Values // Authorization request of each code
+	forgedNonce string
+	exchanged   int
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &mockProvider{t: t, private: private, public: public, grants: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.discovery)
+	mux.HandleFunc("/authorize", provider.authorize)
+	mux.HandleFunc("/token", provider.token)
+	mux.HandleFunc("/jwks", provider.jwks)
+	provider.Server = httptest.NewServer(mux)
+	t.Cleanup(provider.Close)
+
+	return provider
+}
+
+func (p *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/jwks",
+	})
+}
+
+// authorize signs the user in straight away and redirects back with a code
+func (p *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != mockClientID || query.Get("code_challenge_method") != "S256" || query.Get("nonce") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	code := "code-" + strconv.Itoa(len(p.grants)+1)
+	p.grants[code] = query
+	p.mu.Unlock()
+
+	http.Redirect(w, r, query.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {query.Get("state")}}.Encode(),
+		http.StatusFound)
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("client_secret") != mockClientSecret {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	grant, ok := p.grants[r.PostForm.Get("code")]
+	if !ok {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	delete(p.grants, r.PostForm.Get("code")) // Codes are single use
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != grant.Get("code_challenge") {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	p.exchanged++
+
+	nonce := grant.Get("nonce")
+	if p.forgedNonce != "" {
+		nonce = p.forgedNonce
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"iss":            p.URL,
+		"aud":            mockClientID,
+		"sub":            "external-user",
+		"email":          "Someone@Example.com",
+		"email_verified": true,
+		"name":           "Someone",
+		"nonce":          nonce,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = "mock"
+	idToken, err := token.SignedString(p.private)
+	if err != nil {
+		p.t.Error(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+}
+
+func (p *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(models.JSONWebKeySet{Keys: []models.JSONWebKey{
+		{Kty: "OKP", Crv: "Ed25519", Kid: "mock", Alg: "EdDSA", Use: "sig", X: base64.RawURLEncoding.EncodeToString(p.public)},
+	}})
+}
+
+// login follows the authorization URL to the provider and returns the code and state it redirects back with
+func (p *mockProvider) login(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	response, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	callback, err := response.Location()
+	if err != nil {
+		t.Fatalf("provider did not redirect back: %v", err)
+	}
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+// actionTokens keeps the claims of action tokens in memory, session tokens are only counted
+type actionTokens struct {
+	interfaces.TokenService
+
+	mu       sync.Mutex
+	claims   map[string]map[string]any
+	sessions int
+}
+
+func (s *actionTokens) GenerateToken(context.Context, *models.User, []string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions++
+	return "session-" + strconv.Itoa(s.sessions), nil
+}
+
+func (s *actionTokens) GenerateActionToken(purpose string, claims map[string]any, _ time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := purpose + "-" + strconv.Itoa(len(s.claims)+1)
+	s.claims[token] = map[string]any{"purpose": purpose}
+	for key, value := range claims {
+		s.claims[token][key] = value
+	}
+	return token, nil
+}
+
+func (s *actionTokens) ParseActionToken(purpose string, token string) (jwt.MapClaims, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claims, ok := s.claims[token]
+	if !ok || claims["purpose"] != purpose {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// oidcRepositories keeps users, identities and two-factor enrolments in memory, the methods the
+// use case does not call panic
+type oidcRepositories struct {
+	interfaces.UsersRepository
+
+	mu         sync.Mutex
+	users      map[string]*models.User
+	identities map[string]*models.Identity
+	twoFactor  map[string]*models.TwoFactor
+}
+
+func newOIDCRepositories() *oidcRepositories {
+	return &oidcRepositories{users: map[string]*models.User{}, identities: map[string]*models.Identity{},
+		twoFactor: map[string]*models.TwoFactor{}}
+}
+
+func (r *oidcRepositories) Read(_ context.Context, id string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return nil, models.ErrNotFound
+}
+
+func (r *oidcRepositories) Find(_ context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (r *oidcRepositories) Create(_ context.Context, user *models.User) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *oidcRepositories) MarkEmailVerified(_ context.Context, id string, _ string, verifiedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[id].EmailVerifiedAt = &verifiedAt
+	return nil
+}
+
+type identitiesRepository struct{ *oidcRepositories }
+
+func (r identitiesRepository) Create(_ context.Context, identity *models.Identity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.identities[identity.Provider+"/"+identity.Subject] = identity
+	return nil
+}
+
+func (r identitiesRepository) Read(_ context.Context, provider string, subject string) (*models.Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if identity, ok := r.identities[provider+"/"+subject]; ok {
+		return identity, nil
+	}
+	return nil, models.ErrNotFound
+}
+
+type twoFactorRepository struct {
+	interfaces.TwoFactorRepository
+	*oidcRepositories
+}
+
+func (r twoFactorRepository) Read(_ context.Context, userID string) (*models.TwoFactor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if twoFactor, ok := r.twoFactor[userID]; ok {
+		return twoFactor, nil
+	}
+	return nil, models.ErrNotFound
+}
+
+type auditRepository struct{ interfaces.AuditRepository }
+
+func (auditRepository) Create(context.Context, *models.AuditEntry) error {
+	return nil
+}
+
+type hashService struct{ interfaces.HashService }
+
+func (hashService) HashPassword(password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+type sequentialIDs struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (s *sequentialIDs) GenerateID(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	return "id-" + strconv.Itoa(s.next), nil
+}
+
+type fixedClock struct{}
+
+func (fixedClock) Now() time.Time {
+	return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+type oidcTest struct {
+	provider     *mockProvider
+	repositories *oidcRepositories
+	tokens       *actionTokens
+	usecase      *OIDCUseCase
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	provider := newMockProvider(t)
+	repositories := newOIDCRepositories()
+	tokens := &actionTokens{claims: map[string]map[string]any{}}
+	ids := &sequentialIDs{}
+	auditor := NewAuditor(auditRepository{}, ids, fixedClock{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	usecase := NewOIDCUseCase(repositories, identitiesRepository{repositories}, map[string]interfaces.IdentityProvider{
+		"mock": services.NewOIDCProvider(provider.URL, mockClientID, mockClientSecret, mockRedirectURL),
+	}, hashService{}, tokens, ids, fixedClock{}, auditor, twoFactorRepository{oidcRepositories: repositories})
+
+	return &oidcTest{provider, repositories, tokens, usecase}
+}
+
+// begin starts a login and signs in at the provider, returning the flow token, code and state
+func (o *oidcTest) begin(t *testing.T) (string, string, string) {
+	t.Helper()
+	authURL, flowToken, err := o.usecase.BeginLogin(context.Background(), "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := o.provider.login(t, authURL)
+	return flowToken, code, state
+}
+
+func TestOIDCLoginCreatesTheUser(t *testing.T) {
+	o := newOIDCTest(t)
+	flowToken, code, state := o.begin(t)
+
+	result, err := o.usecase.CompleteLogin(context.Background(), "mock", flowToken, state, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Token == "" || result.TwoFactorRequired {
+		t.Errorf("CompleteLogin() = %+v, want a token", result)
+	}
+	if o.provider.exchanged != 1 {
+		t.Errorf("the code was exchanged %d times, want once", o.provider.exchanged)
+	}
+	user, err := o.repositories.Find(context.Background(), "someone@example.com")
+	if err != nil || user.DisplayName != "Someone" || !user.EmailVerified() {
+		t.Errorf("created user = %+v, %v", user, err)
+	}
+	if identity := o.repositories.identities["mock/external-user"]; identity == nil || identity.UserID != user.ID {
+		t.Errorf("linked identity = %+v, want one for %s", identity, user.ID)
+	}
+}
+
+func TestOIDCLoginEnforcesPKCE(t *testing.T) {
+	o := newOIDCTest(t)
+	flowToken, code, state := o.begin(t)
+	o.tokens.claims[flowToken]["verifier"] = "not-the-verifier"
+
+	_, err := o.usecase.CompleteLogin(context.Background(), "mock", flowToken, state, code)
+	if !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("CompleteLogin() error = %v, want unauthorized", err)
+	}
+	if o.provider.exchanged != 0 {
+		t.Error("the provider accepted a code without its verifier")
+	}
+}
+
+func TestOIDCLoginChecksTheNonce(t *testing.T) {
+	o := newOIDCTest(t)
+	o.provider.forgedNonce = "replayed-nonce"
+	flowToken, code, state := o.begin(t)
+
+	_, err := o.usecase.CompleteLogin(context.Background(), "mock", flowToken, state, code)
+	if !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("CompleteLogin() error = %v, want unauthorized", err)
+	}
+	if len(o.repositories.users) != 0 {
+		t.Error("a user was created from an ID token with another nonce")
+	}
+}
+
+func TestOIDCLoginChecksTheState(t *testing.T) {
+	o := newOIDCTest(t)
+	flowToken, code, _ := o.begin(t)
+
+	_, err := o.usecase.CompleteLogin(context.Background(), "mock", flowToken, "forged-state", code)
+	if !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("CompleteLogin() error = %v, want unauthorized", err)
+	}
+}
+
+func TestOIDCLoginChallengesTheSecondFactor(t *testing.T) {
+	o := newOIDCTest(t)
+	enabledAt := fixedClock{}.Now()
+	o.repositories.users["user"] = &models.User{ID: "user", Email: "someone@example.com"}
+	o.repositories.identities["mock/external-user"] = &models.Identity{Provider: "mock", Subject: "external-user", UserID: "user"}
+	o.repositories.twoFactor["user"] = &models.TwoFactor{UserID: "user", EnabledAt: &enabledAt}
+	flowToken, code, state := o.begin(t)
+
+	result, err := o.usecase.CompleteLogin(context.Background(), "mock", flowToken, state, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.TwoFactorRequired || result.Token != "" || o.tokens.sessions != 0 {
+		t.Fatalf("CompleteLogin() = %+v with %d sessions, want only a challenge", result, o.tokens.sessions)
+	}
+	claims, err := o.tokens.ParseActionToken(twoFactorChallengePurpose, result.ChallengeToken)
+	if err != nil || claims["user_id"] != "user" {
+		t.Errorf("challenge claims = %v, %v", claims, err)
+	}
+}
@@ -0,0 +1,54 @@
+package usecases
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// PasswordScreen rejects new passwords that are breached or too easy to guess
+type PasswordScreen struct {
+	breachedPasswords interfaces.BreachedPasswordsService
+	passwordStrength  interfaces.PasswordStrengthService
+	minScore          int
+}
+
+// PasswordScreen constructor, minScore is the lowest strength score accepted, from 0 to 4
+func NewPasswordScreen(breachedPasswords interfaces.BreachedPasswordsService, passwordStrength interfaces.PasswordStrengthService,
+	minScore int) *PasswordScreen {
+	return &PasswordScreen{breachedPasswords, passwordStrength, minScore}
+}
+
+// Check validates a new password, userInputs are values such as the user's email it should not be
+// made of. Rejections tell how to choose a better password.
+func (s *PasswordScreen) Check(ctx context.Context, password string, userInputs ...string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+
+	// Only the first 5 characters of the hash leave this function
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := s.breachedPasswords.Range(ctx, hash[:5])
+	if err != nil {
+		return err
+	}
+	if slices.Contains(suffixes, hash[5:]) {
+		return fmt.Errorf("%w: password appears in a list of breached passwords, choose one you have not used before",
+			models.ErrInvalidInput)
+	}
+
+	strength := s.passwordStrength.Estimate(password, userInputs...)
+	if strength.Score < s.minScore {
+		return fmt.Errorf("%w: password is too easy to guess (strength %d of 4, %d required): %s", models.ErrInvalidInput,
+			strength.Score, s.minScore, strings.Join(strength.Feedback, "; "))
+	}
+
+	return nil
+}
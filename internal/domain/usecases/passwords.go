@@ -0,0 +1,218 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// passwordResetTTL is how long a reset link stays valid
+const passwordResetTTL = time.Hour
+
+type PasswordsUseCase struct {
+	usersRepository  interfaces.UsersRepository
+	resetsRepository interfaces.PasswordResetsRepository
+	hashService      interfaces.HashService
+	tokenService     interfaces.TokenService
+	uuidService      interfaces.UUIDService
+	mailerService    interfaces.MailerService
+	clockService     interfaces.ClockService
+	auditor          *Auditor
+	signinGuard      *SigninGuard
+	passwordScreen   *PasswordScreen
+	resetURL         string
+	sessions         interfaces.SessionsRepository
+	logger           *slog.Logger
+}
+
+// Passwords usecases constructor, resetURL is the page the reset token is appended to
+func NewPasswordsUseCase(
+	usersRepository interfaces.UsersRepository,
+	resetsRepository interfaces.PasswordResetsRepository,
+	hashService interfaces.HashService,
+	tokenService interfaces.TokenService,
+	uuidService interfaces.UUIDService,
+	mailerService interfaces.MailerService,
+	clockService interfaces.ClockService,
+	auditor *Auditor,
+	signinGuard *SigninGuard,
+	passwordScreen *PasswordScreen,
+	resetURL string,
+	sessions interfaces.SessionsRepository,
+	logger *slog.Logger,
+) *PasswordsUseCase {
+	return &PasswordsUseCase{usersRepository, resetsRepository, hashService, tokenService, uuidService, mailerService, clockService,
+		auditor, signinGuard, passwordScreen, resetURL, sessions, logger.With("component", "passwords")}
+}
+
+// ChangePassword replaces the token user's password, revoking all their sessions,
+// and returns a fresh token for the caller
+func (uc *PasswordsUseCase) ChangePassword(ctx context.Context, token string, oldPassword string, newPassword string) (string, error) {
+	claims, err := uc.tokenService.ParseToken(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return "", errors.New("invalid user ID in token")
+	}
+
+	user, err := uc.usersRepository.Read(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	// Wrong old passwords count towards the signin lockout, so a stolen token cannot guess the password
+	if err := uc.signinGuard.Reserve(ctx, user.Email); err != nil {
+		return "", err
+	}
+	if !uc.hashService.ComparePassword(oldPassword, user.Password) {
+		return "", fmt.Errorf("%w: invalid password", models.ErrUnauthorized)
+	}
+	uc.signinGuard.Release(ctx, user.Email)
+	if err := uc.passwordScreen.Check(ctx, newPassword, user.Email, user.DisplayName); err != nil {
+		return "", err
+	}
+
+	if err := uc.setPassword(ctx, user.ID, newPassword); err != nil {
+		return "", err
+	}
+	// The token's own session goes too, the caller carries on with the fresh token
+	if err := uc.sessions.RevokeAll(ctx, user.ID, "", uc.clockService.Now()); err != nil {
+		return "", err
+	}
+	uc.auditor.Record(ctx, user.ID, models.AuditPasswordChanged, models.AuditTargetUser, user.ID, nil, nil)
+
+	// The fresh token is no more privileged than the one it replaces
+	return uc.tokenService.GenerateToken(ctx, user, models.ClaimScopes(claims))
+}
+
+// ForgotPassword emails a reset link if an account exists for the email,
+// without revealing whether it does: failures are logged and success is reported either way
+func (uc *PasswordsUseCase) ForgotPassword(ctx context.Context, email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	user, err := uc.usersRepository.Find(ctx, email)
+	if err != nil {
+		if !errors.Is(err, models.ErrNotFound) {
+			uc.logger.ErrorContext(ctx, "failed to find account for password reset", "error", err)
+		}
+		return nil
+	}
+
+	if err := uc.sendResetLink(ctx, user); err != nil {
+		uc.logger.ErrorContext(ctx, "failed to send password reset link", "user_id", user.ID, "error", err)
+	}
+	return nil
+}
+
+// sendResetLink stores a new reset token for user and emails them a link carrying it
+func (uc *PasswordsUseCase) sendResetLink(ctx context.Context, user *models.User) error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	resetToken := base64.RawURLEncoding.EncodeToString(secret)
+
+	id, err := uc.uuidService.GenerateID(ctx)
+	if err != nil {
+		return err
+	}
+
+	reset := &models.PasswordReset{
+		ID:        id,
+		UserID:    user.ID,
+		TokenHash: hashResetToken(resetToken),
+		ExpiresAt: uc.clockService.Now().Add(passwordResetTTL),
+	}
+	if err := uc.resetsRepository.Create(ctx, reset); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, "", models.AuditPasswordResetRequested, models.AuditTargetUser, user.ID, nil, nil)
+
+	return uc.mailerService.Send(ctx, models.Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account.\n\n"+
+			"Use this link within %s to choose a new one:\n%s?token=%s\n\n"+
+			"If it wasn't you, you can ignore this email.", passwordResetTTL, uc.resetURL, resetToken),
+	})
+}
+
+// ResetPassword sets a new password using a reset token, which can only be used once
+func (uc *PasswordsUseCase) ResetPassword(ctx context.Context, resetToken string, newPassword string) error {
+	// Checked before the token is used up, so a rejected password can be replaced
+	if err := uc.passwordScreen.Check(ctx, newPassword); err != nil {
+		return err
+	}
+
+	userID, err := uc.resetsRepository.Consume(ctx, hashResetToken(resetToken), uc.clockService.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := uc.setPassword(ctx, userID, newPassword); err != nil {
+		return err
+	}
+	if err := uc.sessions.RevokeAll(ctx, userID, "", uc.clockService.Now()); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, userID, models.AuditPasswordReset, models.AuditTargetUser, userID, nil, nil)
+
+	// Proving control of the mailbox lifts a signin lockout
+	user, err := uc.usersRepository.Read(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := uc.signinGuard.Unlock(ctx, user.Email); err != nil {
+		return err
+	}
+
+	// Outstanding links are useless once the password is reset
+	return uc.resetsRepository.DeleteByUser(ctx, userID)
+}
+
+func (uc *PasswordsUseCase) setPassword(ctx context.Context, userID string, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := uc.hashService.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return uc.usersRepository.UpdatePassword(ctx, userID, hashedPassword, uc.clockService.Now())
+}
+
+// maxPasswordLength bounds the work spent hashing a password
+const maxPasswordLength = 256
+
+// validatePassword enforces the password rules
+func validatePassword(password string) error {
+	if len(password) < 8 {
+		return fmt.Errorf("%w: password must be at least 8 characters", models.ErrInvalidInput)
+	}
+	if utf8.RuneCountInString(password) > maxPasswordLength {
+		return fmt.Errorf("%w: password must be at most %d characters", models.ErrInvalidInput, maxPasswordLength)
+	}
+	return nil
+}
+
+// hashResetToken hashes a reset token for storage, the tokens are random so a fast hash is enough
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
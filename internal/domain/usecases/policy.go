@@ -0,0 +1,51 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// Policy decides whether a user may perform an action, based on their email verification and role.
+// Posts are only ever read by their author, so a post hidden by a moderator stays readable to them,
+// flagged by hidden_at, but can no longer be edited and leaves the public event stream.
+type Policy struct {
+	usersRepository   interfaces.UsersRepository
+	unverifiedActions []string
+}
+
+// Policy constructor, unverifiedActions lists what accounts with an unverified email may still do
+func NewPolicy(usersRepository interfaces.UsersRepository, unverifiedActions []string) *Policy {
+	return &Policy{usersRepository, unverifiedActions}
+}
+
+// Authorize returns an error wrapping models.ErrForbidden when the user may not perform the action
+func (p *Policy) Authorize(ctx context.Context, userID string, action string) error {
+	user, err := p.usersRepository.Read(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.EmailVerified() && !slices.Contains(p.unverifiedActions, action) {
+		return fmt.Errorf("%w: verify your email address first", models.ErrForbidden)
+	}
+
+	return nil
+}
+
+// Require returns an error wrapping models.ErrForbidden unless the user's current role grants the permission
+func (p *Policy) Require(ctx context.Context, userID string, permission string) (*models.User, error) {
+	user, err := p.usersRepository.Read(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !models.HasPermission(user.Role, permission) {
+		return nil, fmt.Errorf("%w: missing permission %s", models.ErrForbidden, permission)
+	}
+
+	return user, nil
+}
@@ -3,6 +3,7 @@ package usecases
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jdashel/posts-api/internal/domain/interfaces"
 	"github.com/jdashel/posts-api/internal/domain/models"
@@ -13,18 +14,26 @@ type PostsUseCases struct {
 	tokenService  interfaces.TokenService
 	uuidService   interfaces.UUIDService
 	socketService interfaces.SocketService
+	policy        *Policy
+	auditor       *Auditor
+	metrics       interfaces.MetricsService
+	clockService  interfaces.ClockService
 }
 
 // Posts usecases constructor
 func NewPostsUseCases(repository interfaces.PostsRepository,
-	tokenService interfaces.TokenService, uuidService interfaces.UUIDService, socketService interfaces.SocketService) *PostsUseCases {
-	return &PostsUseCases{repository, tokenService, uuidService, socketService}
+	tokenService interfaces.TokenService, uuidService interfaces.UUIDService, socketService interfaces.SocketService,
+	policy *Policy, auditor *Auditor, metrics interfaces.MetricsService, clockService interfaces.ClockService) *PostsUseCases {
+	return &PostsUseCases{repository, tokenService, uuidService, socketService, policy, auditor, metrics, clockService}
 }
 
 // CreatePost creates a new post
 func (uc *PostsUseCases) CreatePost(ctx context.Context, token string, post *models.Post) (*models.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostsUseCases.CreatePost")
+	defer span.End()
+
 	// Validate user token
-	claims, err := uc.tokenService.ParseToken(token)
+	claims, err := uc.tokenService.ParseToken(ctx, token)
 	if err != nil {
 		return nil, errors.New("invalid token")
 	}
@@ -33,6 +42,10 @@ func (uc *PostsUseCases) CreatePost(ctx context.Context, token string, post *mod
 		return nil, errors.New("invalid user ID in token")
 	}
 
+	if err := uc.policy.Authorize(ctx, authorID, models.ActionCreatePost); err != nil {
+		return nil, err
+	}
+
 	// Generate a UUID for the post
 	postID, err := uc.uuidService.GenerateID(ctx)
 	if err != nil {
@@ -49,19 +62,20 @@ func (uc *PostsUseCases) CreatePost(ctx context.Context, token string, post *mod
 		return nil, err
 	}
 
-	var postMessage = models.SocketMessage{
-		Type:    "post_created",
-		Payload: createdPost,
-	}
-	uc.socketService.Broadcast(postMessage)
+	uc.auditor.Record(ctx, authorID, models.AuditPostCreated, models.AuditTargetPost, createdPost.ID, nil, createdPost)
+	uc.metrics.PostCreated()
+	uc.socketService.Broadcast(models.NewPostCreatedMessage(createdPost, uc.clockService.Now()))
 
 	return createdPost, nil
 }
 
 // GetPostById retrieves a post by ID
 func (uc *PostsUseCases) GetPostById(ctx context.Context, token string, id string) (*models.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostsUseCases.GetPostById")
+	defer span.End()
+
 	// Validate user token
-	claims, err := uc.tokenService.ParseToken(token)
+	claims, err := uc.tokenService.ParseToken(ctx, token)
 	if err != nil {
 		return nil, errors.New("invalid token")
 	}
@@ -75,8 +89,11 @@ func (uc *PostsUseCases) GetPostById(ctx context.Context, token string, id strin
 
 // GetAllPosts retrieves posts with pagination
 func (uc *PostsUseCases) GetAllPosts(ctx context.Context, token string, pageNumber int, pageSize int) ([]*models.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostsUseCases.GetAllPosts")
+	defer span.End()
+
 	// Validate user token
-	claims, err := uc.tokenService.ParseToken(token)
+	claims, err := uc.tokenService.ParseToken(ctx, token)
 	if err != nil {
 		return nil, errors.New("invalid token")
 	}
@@ -93,8 +110,11 @@ func (uc *PostsUseCases) GetAllPosts(ctx context.Context, token string, pageNumb
 
 // UpdatePost updates an existing post
 func (uc *PostsUseCases) UpdatePost(ctx context.Context, token string, id string, post *models.Post) (*models.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostsUseCases.UpdatePost")
+	defer span.End()
+
 	// Validate user token
-	claims, err := uc.tokenService.ParseToken(token)
+	claims, err := uc.tokenService.ParseToken(ctx, token)
 	if err != nil {
 		return nil, errors.New("invalid token")
 	}
@@ -103,13 +123,33 @@ func (uc *PostsUseCases) UpdatePost(ctx context.Context, token string, id string
 		return nil, errors.New("invalid user ID in token")
 	}
 
-	return uc.repository.Update(ctx, id, authorID, post)
+	if err := uc.policy.Authorize(ctx, authorID, models.ActionUpdatePost); err != nil {
+		return nil, err
+	}
+
+	before, err := uc.repository.Read(ctx, id, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedPost, err := uc.repository.Update(ctx, id, authorID, post)
+	if err != nil {
+		return nil, err
+	}
+	uc.auditor.Record(ctx, authorID, models.AuditPostUpdated, models.AuditTargetPost, id, before, updatedPost)
+
+	uc.socketService.Broadcast(models.NewPostUpdatedMessage(updatedPost, uc.clockService.Now()))
+
+	return updatedPost, nil
 }
 
 // DeletePost deletes a post
 func (uc *PostsUseCases) DeletePost(ctx context.Context, token string, id string) error {
+	ctx, span := tracer.Start(ctx, "PostsUseCases.DeletePost")
+	defer span.End()
+
 	// Validate user token
-	claims, err := uc.tokenService.ParseToken(token)
+	claims, err := uc.tokenService.ParseToken(ctx, token)
 	if err != nil {
 		return errors.New("invalid token")
 	}
@@ -117,5 +157,61 @@ func (uc *PostsUseCases) DeletePost(ctx context.Context, token string, id string
 	if err != nil {
 		return errors.New("invalid user ID in token")
 	}
-	return uc.repository.Delete(ctx, id, authorID)
+
+	if err := uc.policy.Authorize(ctx, authorID, models.ActionDeletePost); err != nil {
+		return err
+	}
+
+	before, err := uc.repository.Read(ctx, id, authorID)
+	if err != nil {
+		return err
+	}
+	if err := uc.repository.Delete(ctx, id, authorID); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, authorID, models.AuditPostDeleted, models.AuditTargetPost, id, before, nil)
+
+	uc.socketService.Broadcast(models.NewPostDeletedMessage(id, authorID, uc.clockService.Now()))
+
+	return nil
+}
+
+// SetPostHidden hides or unhides any post, reserved to moderators
+func (uc *PostsUseCases) SetPostHidden(ctx context.Context, token string, id string, hidden bool) (*models.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostsUseCases.SetPostHidden")
+	defer span.End()
+
+	// Validate user token
+	claims, err := uc.tokenService.ParseToken(ctx, token)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, errors.New("invalid user ID in token")
+	}
+
+	if _, err := uc.policy.Require(ctx, userID, models.PermissionModeratePosts); err != nil {
+		return nil, err
+	}
+
+	var hiddenAt *time.Time
+	if hidden {
+		now := uc.clockService.Now()
+		hiddenAt = &now
+	}
+
+	post, err := uc.repository.SetHidden(ctx, id, hiddenAt)
+	if err != nil {
+		return nil, err
+	}
+	action := models.AuditPostUnhidden
+	if hidden {
+		action = models.AuditPostHidden
+	}
+	uc.auditor.Record(ctx, userID, action, models.AuditTargetPost, id, nil, post)
+
+	uc.socketService.Broadcast(models.NewPostUpdatedMessage(post, uc.clockService.Now()))
+
+	return post, nil
 }
@@ -0,0 +1,89 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+type SessionsUseCase struct {
+	repository   interfaces.SessionsRepository
+	tokenService interfaces.TokenService
+	clockService interfaces.ClockService
+	auditor      *Auditor
+}
+
+// Sessions usecases constructor
+func NewSessionsUseCase(
+	repository interfaces.SessionsRepository,
+	tokenService interfaces.TokenService,
+	clockService interfaces.ClockService,
+	auditor *Auditor,
+) *SessionsUseCase {
+	return &SessionsUseCase{repository, tokenService, clockService, auditor}
+}
+
+// GetSessions lists the active sessions of the token's user, flagging the token's own
+func (uc *SessionsUseCase) GetSessions(ctx context.Context, token string) ([]*models.Session, error) {
+	userID, sessionID, err := uc.session(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := uc.repository.Find(ctx, userID, uc.clockService.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == sessionID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession logs the token's user out of one of their sessions, the token's own included
+func (uc *SessionsUseCase) RevokeSession(ctx context.Context, token string, id string) error {
+	userID, _, err := uc.session(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.repository.Revoke(ctx, id, userID, uc.clockService.Now()); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, userID, models.AuditSessionRevoked, models.AuditTargetSession, id, nil, nil)
+
+	return nil
+}
+
+// RevokeOtherSessions logs the token's user out of every session but the token's own.
+// API keys have no session, so every session is revoked.
+func (uc *SessionsUseCase) RevokeOtherSessions(ctx context.Context, token string) error {
+	userID, sessionID, err := uc.session(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.repository.RevokeAll(ctx, userID, sessionID, uc.clockService.Now()); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, userID, models.AuditSessionsRevoked, models.AuditTargetUser, userID, nil, nil)
+
+	return nil
+}
+
+// session returns the user and the session of the token
+func (uc *SessionsUseCase) session(ctx context.Context, token string) (string, string, error) {
+	claims, err := uc.tokenService.ParseToken(ctx, token)
+	if err != nil {
+		return "", "", errors.New("invalid token")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return "", "", errors.New("invalid user ID in token")
+	}
+	sessionID, _ := claims["sid"].(string)
+	return userID, sessionID, nil
+}
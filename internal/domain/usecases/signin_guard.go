@@ -0,0 +1,191 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// SigninGuard slows down and locks out repeated failed signins, per account and per client IP.
+// Every failure on an account doubles the delay before its next attempt, starting at baseDelay,
+// and the account is locked for lockoutDuration once it reaches maxAccountFailures. Client IPs
+// are only locked out, at maxIPFailures, as many users may share one. Counters restart when
+// the last failure is older than lockoutDuration.
+type SigninGuard struct {
+	repository         interfaces.SigninAttemptsRepository
+	clockService       interfaces.ClockService
+	maxAccountFailures int
+	maxIPFailures      int
+	baseDelay          time.Duration
+	lockoutDuration    time.Duration
+	logger             *slog.Logger
+}
+
+// SigninGuard constructor
+func NewSigninGuard(
+	repository interfaces.SigninAttemptsRepository,
+	clockService interfaces.ClockService,
+	maxAccountFailures int,
+	maxIPFailures int,
+	baseDelay time.Duration,
+	lockoutDuration time.Duration,
+	logger *slog.Logger,
+) *SigninGuard {
+	return &SigninGuard{repository, clockService, maxAccountFailures, maxIPFailures, baseDelay, lockoutDuration,
+		logger.With("component", "signin_guard")}
+}
+
+// reserveTries bounds how many times a counter changed by concurrent attempts is read again
+const reserveTries = 5
+
+// Reserve refuses the attempt with a RetryError while the account or the client IP must wait.
+// Otherwise it counts the attempt as failed before the credentials are even checked, so concurrent
+// attempts each see the ones before them, until Release takes it back.
+func (g *SigninGuard) Reserve(ctx context.Context, email string) error {
+	keys := g.keys(ctx, email)
+	attempts, err := g.repository.Find(ctx, keys)
+	if err != nil {
+		return err
+	}
+	if err := g.refuse(attempts); err != nil {
+		return err
+	}
+
+	reserved := []string{}
+	for _, key := range keys {
+		if err := g.reserve(ctx, key, attempts); err != nil {
+			g.release(ctx, reserved)
+			return err
+		}
+		reserved = append(reserved, key)
+	}
+	return nil
+}
+
+// reserve counts an attempt against the key, reading its counter again whenever a concurrent
+// attempt changed it in the meantime
+func (g *SigninGuard) reserve(ctx context.Context, key string, attempts []*models.SigninAttempts) error {
+	for try := 0; try < reserveTries; try++ {
+		var seen *models.SigninAttempts
+		for _, attempt := range attempts {
+			if attempt.Key == key {
+				seen = attempt
+			}
+		}
+
+		now := g.clockService.Now()
+		attempt, err := g.repository.Increment(ctx, key, seen, now, now.Add(-g.lockoutDuration))
+		if err != nil {
+			return err
+		}
+		if attempt != nil {
+			if attempt.Failures == g.maxFailures(key) {
+				g.logger.WarnContext(ctx, "locked out unless the attempt succeeds", "key", key,
+					"until", g.retryAt(attempt), "failures", attempt.Failures)
+			}
+			return nil
+		}
+
+		if attempts, err = g.repository.Find(ctx, []string{key}); err != nil {
+			return err
+		}
+		if err := g.refuse(attempts); err != nil {
+			return err
+		}
+	}
+	return g.retryError(max(g.baseDelay, time.Second))
+}
+
+// refuse returns a RetryError when one of the counters must still wait
+func (g *SigninGuard) refuse(attempts []*models.SigninAttempts) error {
+	now := g.clockService.Now()
+	var wait time.Duration
+	for _, attempt := range attempts {
+		if until := g.retryAt(attempt); until.Sub(now) > wait {
+			wait = until.Sub(now)
+		}
+	}
+	if wait <= 0 {
+		return nil
+	}
+	return g.retryError(wait)
+}
+
+func (g *SigninGuard) retryError(wait time.Duration) error {
+	return &models.RetryError{
+		Message:    fmt.Sprintf("too many failed signin attempts, retry in %s", wait.Round(time.Second)),
+		RetryAfter: wait,
+	}
+}
+
+// Release takes back a reserved attempt once the credentials turned out right. The wait of the
+// account still starts from the attempt.
+func (g *SigninGuard) Release(ctx context.Context, email string) {
+	g.release(ctx, g.keys(ctx, email))
+}
+
+func (g *SigninGuard) release(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := g.repository.Decrement(ctx, key); err != nil {
+			g.logger.ErrorContext(ctx, "failed to release attempt", "error", err)
+		}
+	}
+}
+
+// Succeeded clears the failures of the account, those of the client IP are kept
+func (g *SigninGuard) Succeeded(ctx context.Context, email string) {
+	if err := g.repository.Delete(ctx, accountKey(email)); err != nil {
+		g.logger.ErrorContext(ctx, "failed to clear attempts", "error", err)
+	}
+}
+
+// Unlock lifts the delay or lockout of an account, after its password was reset
+func (g *SigninGuard) Unlock(ctx context.Context, email string) error {
+	if err := g.repository.Delete(ctx, accountKey(email)); err != nil {
+		return err
+	}
+	g.logger.InfoContext(ctx, "unlocked", "key", accountKey(email))
+	return nil
+}
+
+// retryAt tells when the next attempt is allowed
+func (g *SigninGuard) retryAt(attempt *models.SigninAttempts) time.Time {
+	if attempt.Failures < 1 {
+		return attempt.LastFailureAt
+	}
+	if attempt.Failures >= g.maxFailures(attempt.Key) {
+		return attempt.LastFailureAt.Add(g.lockoutDuration)
+	}
+	if !strings.HasPrefix(attempt.Key, "email:") {
+		return attempt.LastFailureAt
+	}
+
+	delay := g.baseDelay << min(attempt.Failures-1, 30)
+	return attempt.LastFailureAt.Add(min(delay, g.lockoutDuration))
+}
+
+func (g *SigninGuard) maxFailures(key string) int {
+	if strings.HasPrefix(key, "email:") {
+		return g.maxAccountFailures
+	}
+	return g.maxIPFailures
+}
+
+// keys returns the counters an attempt is checked against, unknown emails are tracked
+// too so lockouts do not reveal which accounts exist
+func (g *SigninGuard) keys(ctx context.Context, email string) []string {
+	keys := []string{accountKey(email)}
+	if ip := models.RequestInfoFromContext(ctx).IP; ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+func accountKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,262 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// attemptsRepository keeps signin counters in memory, with the compare-and-increment semantics of
+// the database. When burst is set, the first reads wait for each other like concurrent requests.
+type attemptsRepository struct {
+	mu       sync.Mutex
+	attempts map[string]models.SigninAttempts
+	burst    int
+	burstAll chan struct{}
+}
+
+func newAttemptsRepository(burst int) *attemptsRepository {
+	return &attemptsRepository{attempts: map[string]models.SigninAttempts{}, burst: burst, burstAll: make(chan struct{})}
+}
+
+func (r *attemptsRepository) Find(_ context.Context, keys []string) ([]*models.SigninAttempts, error) {
+	r.mu.Lock()
+	found := []*models.SigninAttempts{}
+	for _, key := range keys {
+		if attempt, ok := r.attempts[key]; ok && attempt.Failures > 0 {
+			found = append(found, &attempt)
+		}
+	}
+	wait := r.burst > 0
+	if wait {
+		if r.burst--; r.burst == 0 {
+			close(r.burstAll)
+		}
+	}
+	r.mu.Unlock()
+
+	if wait {
+		<-r.burstAll
+	}
+	return found, nil
+}
+
+func (r *attemptsRepository) Increment(_ context.Context, key string, seen *models.SigninAttempts, now time.Time,
+	since time.Time) (*models.SigninAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.attempts[key]
+	if seen == nil && ok && current.Failures > 0 && !current.LastFailureAt.Before(since) {
+		return nil, nil
+	}
+	if seen != nil && (current.Failures != seen.Failures || !current.LastFailureAt.Equal(seen.LastFailureAt)) {
+		return nil, nil
+	}
+
+	if !ok || current.LastFailureAt.Before(since) {
+		current = models.SigninAttempts{Key: key}
+	}
+	current.Failures++
+	current.LastFailureAt = now
+	r.attempts[key] = current
+	return &current, nil
+}
+
+func (r *attemptsRepository) Decrement(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if attempt, ok := r.attempts[key]; ok && attempt.Failures > 0 {
+		attempt.Failures--
+		r.attempts[key] = attempt
+	}
+	return nil
+}
+
+func (r *attemptsRepository) Delete(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, key)
+	return nil
+}
+
+func (r *attemptsRepository) failures(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attempts[key].Failures
+}
+
+// manualClock only moves when advanced
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+const guardedEmail = "user@example.com"
+
+// newSigninGuard allows 5 failures per account and 10 per IP, waits 1s after the first one and
+// locks out for 15m
+func newSigninGuard(repository *attemptsRepository) (*SigninGuard, *manualClock) {
+	clock := &manualClock{now: fixedClock{}.Now()}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewSigninGuard(repository, clock, 5, 10, time.Second, 15*time.Minute, logger), clock
+}
+
+func fromIP(ip string) context.Context {
+	return models.WithRequestInfo(context.Background(), models.RequestInfo{IP: ip})
+}
+
+// retryAfter returns how long the refused attempt must wait, failing when it was not refused
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var retryErr *models.RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("err = %v, want a RetryError", err)
+	}
+	return retryErr.RetryAfter
+}
+
+func TestSigninGuardDoublesTheDelay(t *testing.T) {
+	guard, clock := newSigninGuard(newAttemptsRepository(0))
+	ctx := fromIP("192.0.2.1")
+
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if err := guard.Reserve(ctx, guardedEmail); err != nil {
+			t.Fatalf("attempt refused before its delay: %v", err)
+		}
+		if wait := retryAfter(t, guard.Reserve(ctx, guardedEmail)); wait != delay {
+			t.Fatalf("retry after %s, want %s", wait, delay)
+		}
+		clock.advance(delay)
+	}
+}
+
+func TestSigninGuardLocksOutAndForgets(t *testing.T) {
+	repository := newAttemptsRepository(0)
+	guard, clock := newSigninGuard(repository)
+	ctx := fromIP("192.0.2.1")
+
+	for i := 0; i < 5; i++ {
+		if err := guard.Reserve(ctx, guardedEmail); err != nil {
+			t.Fatal(err)
+		}
+		clock.advance(time.Minute)
+	}
+	if wait := retryAfter(t, guard.Reserve(ctx, guardedEmail)); wait != 14*time.Minute {
+		t.Fatalf("retry after %s, want the rest of the lockout", wait)
+	}
+
+	// Failures are forgotten once the last one is older than the lockout
+	clock.advance(15 * time.Minute)
+	if err := guard.Reserve(ctx, guardedEmail); err != nil {
+		t.Fatal(err)
+	}
+	if failures := repository.failures(accountKey(guardedEmail)); failures != 1 {
+		t.Fatalf("failures = %d, want a new count", failures)
+	}
+}
+
+func TestSigninGuardUnlock(t *testing.T) {
+	guard, clock := newSigninGuard(newAttemptsRepository(0))
+	ctx := fromIP("192.0.2.1")
+
+	for i := 0; i < 5; i++ {
+		if err := guard.Reserve(ctx, guardedEmail); err != nil {
+			t.Fatal(err)
+		}
+		clock.advance(time.Minute)
+	}
+	if err := guard.Unlock(ctx, guardedEmail); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Reserve(ctx, guardedEmail); err != nil {
+		t.Fatalf("attempt refused after the unlock: %v", err)
+	}
+}
+
+func TestSigninGuardReleasesRightCredentials(t *testing.T) {
+	repository := newAttemptsRepository(0)
+	guard, _ := newSigninGuard(repository)
+	ctx := fromIP("192.0.2.1")
+
+	if err := guard.Reserve(ctx, guardedEmail); err != nil {
+		t.Fatal(err)
+	}
+	guard.Release(ctx, guardedEmail)
+
+	if failures := repository.failures(accountKey(guardedEmail)); failures != 0 {
+		t.Errorf("account failures = %d after the release", failures)
+	}
+	if failures := repository.failures("ip:192.0.2.1"); failures != 0 {
+		t.Errorf("IP failures = %d after the release", failures)
+	}
+	if err := guard.Reserve(ctx, guardedEmail); err != nil {
+		t.Errorf("released attempt still delays the next one: %v", err)
+	}
+}
+
+func TestSigninGuardLocksOutTheIP(t *testing.T) {
+	guard, _ := newSigninGuard(newAttemptsRepository(0))
+	ctx := fromIP("192.0.2.1")
+
+	for i := 0; i < 10; i++ {
+		if err := guard.Reserve(ctx, string(rune('a'+i))+"@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait := retryAfter(t, guard.Reserve(ctx, guardedEmail)); wait != 15*time.Minute {
+		t.Fatalf("retry after %s, want the IP lockout", wait)
+	}
+	if err := guard.Reserve(fromIP("192.0.2.2"), guardedEmail); err != nil {
+		t.Fatalf("another IP is locked out: %v", err)
+	}
+}
+
+func TestSigninGuardCountsConcurrentAttempts(t *testing.T) {
+	const burst = 20
+	repository := newAttemptsRepository(burst)
+	guard, _ := newSigninGuard(repository)
+
+	errs := make(chan error, burst)
+	for i := 0; i < burst; i++ {
+		go func() {
+			errs <- guard.Reserve(fromIP("192.0.2.1"), guardedEmail)
+		}()
+	}
+
+	allowed := 0
+	for i := 0; i < burst; i++ {
+		if err := <-errs; err == nil {
+			allowed++
+		} else {
+			retryAfter(t, err)
+		}
+	}
+	if allowed != 1 {
+		t.Errorf("%d concurrent attempts allowed, want 1", allowed)
+	}
+	if failures := repository.failures(accountKey(guardedEmail)); failures != 1 {
+		t.Errorf("account failures = %d, want 1", failures)
+	}
+	if failures := repository.failures("ip:192.0.2.1"); failures != 1 {
+		t.Errorf("IP failures = %d, want the refused attempts released", failures)
+	}
+}
@@ -0,0 +1,6 @@
+package usecases
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts the spans of use cases, between those of requests and of their queries
+var tracer = otel.Tracer("github.com/jdashel/posts-api/internal/domain/usecases")
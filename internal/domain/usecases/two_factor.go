@@ -0,0 +1,258 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+const (
+	twoFactorChallengePurpose = "2fa_challenge"
+	twoFactorChallengeTTL     = 5 * time.Minute
+	recoveryCodeCount         = 10
+	recoveryCodeAlphabet      = "abcdefghjkmnpqrstuvwxyz23456789" // No look-alike characters
+)
+
+type TwoFactorUseCase struct {
+	usersRepository     interfaces.UsersRepository
+	twoFactorRepository interfaces.TwoFactorRepository
+	totpService         interfaces.TOTPService
+	hashService         interfaces.HashService
+	tokenService        interfaces.TokenService
+	uuidService         interfaces.UUIDService
+	clockService        interfaces.ClockService
+	signinGuard         *SigninGuard
+	auditor             *Auditor
+}
+
+// Two-factor usecases constructor
+func NewTwoFactorUseCase(
+	usersRepository interfaces.UsersRepository,
+	twoFactorRepository interfaces.TwoFactorRepository,
+	totpService interfaces.TOTPService,
+	hashService interfaces.HashService,
+	tokenService interfaces.TokenService,
+	uuidService interfaces.UUIDService,
+	clockService interfaces.ClockService,
+	signinGuard *SigninGuard,
+	auditor *Auditor,
+) *TwoFactorUseCase {
+	return &TwoFactorUseCase{usersRepository, twoFactorRepository, totpService, hashService, tokenService, uuidService, clockService,
+		signinGuard, auditor}
+}
+
+// Enroll generates a new secret for the token's user, it only takes effect once enabled
+func (uc *TwoFactorUseCase) Enroll(ctx context.Context, token string) (*models.TwoFactorEnrollment, error) {
+	user, err := uc.user(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := uc.totpService.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	twoFactor := &models.TwoFactor{UserID: user.ID, Secret: secret, CreatedAt: uc.clockService.Now()}
+	if err := uc.twoFactorRepository.Save(ctx, twoFactor); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollment{Secret: secret, URI: uc.totpService.URI(secret, user.Email)}, nil
+}
+
+// Enable confirms the enrolment with a code from the authenticator app and returns the recovery codes
+func (uc *TwoFactorUseCase) Enable(ctx context.Context, token string, code string) ([]string, error) {
+	user, err := uc.user(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactor, err := uc.twoFactorRepository.Read(ctx, user.ID)
+	if errors.Is(err, models.ErrNotFound) || (err == nil && twoFactor.Enabled()) {
+		return nil, fmt.Errorf("%w: no pending two-factor enrolment", models.ErrInvalidInput)
+	} else if err != nil {
+		return nil, err
+	}
+
+	step, ok := uc.totpService.Validate(twoFactor.Secret, normalizeCode(code), uc.clockService.Now())
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid code", models.ErrInvalidInput)
+	}
+
+	recoveryCodes, err := uc.replaceRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.twoFactorRepository.Enable(ctx, user.ID, uc.clockService.Now(), step); err != nil {
+		return nil, err
+	}
+	uc.auditor.Record(ctx, user.ID, models.AuditTwoFactorEnabled, models.AuditTargetUser, user.ID, nil, nil)
+
+	return recoveryCodes, nil
+}
+
+// Disable turns two-factor authentication off, the user proves both factors again
+func (uc *TwoFactorUseCase) Disable(ctx context.Context, token string, password string, code string) error {
+	user, err := uc.user(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if !uc.hashService.ComparePassword(password, user.Password) {
+		return fmt.Errorf("%w: invalid password", models.ErrUnauthorized)
+	}
+
+	twoFactor, err := uc.twoFactorRepository.Read(ctx, user.ID)
+	if errors.Is(err, models.ErrNotFound) || (err == nil && !twoFactor.Enabled()) {
+		return fmt.Errorf("%w: two-factor authentication is not enabled", models.ErrInvalidInput)
+	} else if err != nil {
+		return err
+	}
+
+	if err := uc.verifyCode(ctx, twoFactor, code); err != nil {
+		return err
+	}
+
+	if err := uc.twoFactorRepository.Delete(ctx, user.ID); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, user.ID, models.AuditTwoFactorDisabled, models.AuditTargetUser, user.ID, nil, nil)
+
+	return nil
+}
+
+// CompleteSignin finishes a signin started with a password, failed codes count towards the signin lockout
+func (uc *TwoFactorUseCase) CompleteSignin(ctx context.Context, challengeToken string, code string) (string, error) {
+	claims, err := uc.tokenService.ParseActionToken(twoFactorChallengePurpose, challengeToken)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid or expired challenge", models.ErrUnauthorized)
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return "", errors.New("invalid user ID in token")
+	}
+
+	user, err := uc.usersRepository.Read(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if user.Suspended() {
+		return "", fmt.Errorf("%w: account is suspended", models.ErrForbidden)
+	}
+
+	if err := uc.signinGuard.Reserve(ctx, user.Email); err != nil {
+		return "", err
+	}
+
+	twoFactor, err := uc.twoFactorRepository.Read(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if err := uc.verifyCode(ctx, twoFactor, code); err != nil {
+		uc.auditor.Record(ctx, "", models.AuditSigninFailed, models.AuditTargetUser, user.ID, nil, nil)
+		return "", err
+	}
+	uc.signinGuard.Release(ctx, user.Email)
+
+	token, err := uc.tokenService.GenerateToken(ctx, user, models.ClaimScopes(claims))
+	if err != nil {
+		return "", err
+	}
+	uc.signinGuard.Succeeded(ctx, user.Email)
+	uc.auditor.Record(ctx, user.ID, models.AuditSignin, models.AuditTargetUser, user.ID, nil, nil)
+
+	return token, nil
+}
+
+// verifyCode accepts a TOTP code not used before or an unused recovery code
+func (uc *TwoFactorUseCase) verifyCode(ctx context.Context, twoFactor *models.TwoFactor, code string) error {
+	code = normalizeCode(code)
+
+	if step, ok := uc.totpService.Validate(twoFactor.Secret, code, uc.clockService.Now()); ok {
+		return uc.twoFactorRepository.UseStep(ctx, twoFactor.UserID, step)
+	}
+
+	recoveryCodes, err := uc.twoFactorRepository.FindRecoveryCodes(ctx, twoFactor.UserID)
+	if err != nil {
+		return err
+	}
+	for _, recoveryCode := range recoveryCodes {
+		if uc.hashService.ComparePassword(code, recoveryCode.CodeHash) {
+			if err := uc.twoFactorRepository.UseRecoveryCode(ctx, recoveryCode.ID, uc.clockService.Now()); err != nil {
+				return err
+			}
+			uc.auditor.Record(ctx, twoFactor.UserID, models.AuditRecoveryCodeUsed, models.AuditTargetUser, twoFactor.UserID, nil,
+				map[string]int{"remaining": len(recoveryCodes) - 1})
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: invalid code", models.ErrUnauthorized)
+}
+
+// replaceRecoveryCodes generates new recovery codes, formatted "xxxxx-xxxxx", and stores their hashes
+func (uc *TwoFactorUseCase) replaceRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	codes := make([]*models.RecoveryCode, 0, recoveryCodeCount)
+	for len(plain) < recoveryCodeCount {
+		code := make([]byte, 10)
+		for i := range code {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+			if err != nil {
+				return nil, err
+			}
+			code[i] = recoveryCodeAlphabet[n.Int64()]
+		}
+
+		id, err := uc.uuidService.GenerateID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		codeHash, err := uc.hashService.HashPassword(string(code))
+		if err != nil {
+			return nil, err
+		}
+
+		plain = append(plain, string(code[:5])+"-"+string(code[5:]))
+		codes = append(codes, &models.RecoveryCode{ID: id, UserID: userID, CodeHash: codeHash})
+	}
+
+	if err := uc.twoFactorRepository.ReplaceRecoveryCodes(ctx, userID, codes); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+func (uc *TwoFactorUseCase) user(ctx context.Context, token string) (*models.User, error) {
+	claims, err := uc.tokenService.ParseToken(ctx, token)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, errors.New("invalid user ID in token")
+	}
+	return uc.usersRepository.Read(ctx, userID)
+}
+
+// normalizeCode strips the separators users type or paste along with codes
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+// twoFactorChallenge returns a challenge token standing for a correct password, exchanged
+// for a token by CompleteSignin
+func twoFactorChallenge(tokenService interfaces.TokenService, user *models.User, scopes []string) (string, error) {
+	if scopes == nil {
+		scopes = models.Scopes
+	}
+	claims := map[string]any{"user_id": user.ID, "scopes": scopes}
+	return tokenService.GenerateActionToken(twoFactorChallengePurpose, claims, twoFactorChallengeTTL)
+}
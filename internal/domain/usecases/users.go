@@ -4,16 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/jdashel/posts-api/internal/domain/interfaces"
 	"github.com/jdashel/posts-api/internal/domain/models"
 )
 
 type UsersUseCase struct {
-	repository   interfaces.UsersRepository
-	hashService  interfaces.HashService
-	tokenService interfaces.TokenService
-	uuidService  interfaces.UUIDService
+	repository     interfaces.UsersRepository
+	hashService    interfaces.HashService
+	tokenService   interfaces.TokenService
+	uuidService    interfaces.UUIDService
+	socketService  interfaces.SocketService
+	verification   interfaces.VerificationUseCase
+	deletionPolicy models.DeletionPolicy
+	auditor        *Auditor
+	signinGuard    *SigninGuard
+	twoFactor      interfaces.TwoFactorRepository
+	passwordScreen *PasswordScreen
+	logger         *slog.Logger
+	metrics        interfaces.MetricsService
+	clockService   interfaces.ClockService
+	// dummyHash is compared against when the email is unknown, so signin takes as long either way
+	dummyHash func() string
 }
 
 // Users usecases constructor
@@ -22,12 +41,42 @@ func NewUsersUseCase(
 	hashService interfaces.HashService,
 	tokenService interfaces.TokenService,
 	uuidService interfaces.UUIDService,
+	socketService interfaces.SocketService,
+	verification interfaces.VerificationUseCase,
+	deletionPolicy models.DeletionPolicy,
+	auditor *Auditor,
+	signinGuard *SigninGuard,
+	twoFactor interfaces.TwoFactorRepository,
+	passwordScreen *PasswordScreen,
+	logger *slog.Logger,
+	metrics interfaces.MetricsService,
+	clockService interfaces.ClockService,
 ) *UsersUseCase {
-	return &UsersUseCase{repository, hashService, tokenService, uuidService}
+	logger = logger.With("component", "users")
+	dummyHash := sync.OnceValue(func() string {
+		hash, err := hashService.HashPassword("signin timing equalizer")
+		if err != nil {
+			logger.Error("failed to hash the dummy password", "error", err)
+		}
+		return hash
+	})
+	return &UsersUseCase{repository, hashService, tokenService, uuidService, socketService, verification, deletionPolicy, auditor,
+		signinGuard, twoFactor, passwordScreen, logger, metrics, clockService, dummyHash}
 }
 
 // Signup creates a new user account
 func (uc *UsersUseCase) Signup(ctx context.Context, email, password string) (string, error) {
+	ctx, span := tracer.Start(ctx, "UsersUseCase.Signup")
+	defer span.End()
+
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return "", err
+	}
+	if err := uc.passwordScreen.Check(ctx, password, email); err != nil {
+		return "", err
+	}
+
 	// Hash password using hashService
 	hashedPassword, err := uc.hashService.HashPassword(password)
 	if err != nil {
@@ -47,14 +96,21 @@ func (uc *UsersUseCase) Signup(ctx context.Context, email, password string) (str
 		Password: password,
 	}
 
-	// Create user account in repository
-	_, err = uc.repository.Create(ctx, user)
+	// Create user account in repository, unverified until the email is confirmed
+	user, err = uc.repository.Create(ctx, user)
 	if err != nil {
 		return "", err
 	}
+	uc.auditor.Record(ctx, user.ID, models.AuditSignup, models.AuditTargetUser, user.ID, nil, user)
+	uc.metrics.UserSignedUp()
+
+	// The account exists either way, a failed email can be resent later
+	if err := uc.verification.SendVerification(ctx, user); err != nil {
+		uc.logger.ErrorContext(ctx, "failed to send verification email", "user", user, "error", err)
+	}
 
 	// Generate authentication token using tokenService
-	token, err := uc.tokenService.GenerateToken(user.ID)
+	token, err := uc.tokenService.GenerateToken(ctx, user, nil)
 	if err != nil {
 		return "", err
 	}
@@ -62,35 +118,93 @@ func (uc *UsersUseCase) Signup(ctx context.Context, email, password string) (str
 	return token, nil
 }
 
-// Signin authenticates a user
-func (uc *UsersUseCase) Signin(ctx context.Context, email, password string) (string, error) {
+// Signin authenticates a user, the token carries the requested scopes or all of them when none are.
+// Users with two-factor authentication get a challenge token to complete the signin with instead.
+func (uc *UsersUseCase) Signin(ctx context.Context, email, password string, scopes []string) (*models.SigninResult, error) {
+	ctx, span := tracer.Start(ctx, "UsersUseCase.Signin")
+	defer span.End()
+
+	if err := validateScopes(scopes); err != nil {
+		return nil, err
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	// Refuse passwords over the limit before spending a hash on them. Accounts whose password was set
+	// longer before the limit existed have to reset it.
+	if utf8.RuneCountInString(password) > maxPasswordLength {
+		return nil, fmt.Errorf("%w: password must be at most %d characters", models.ErrInvalidInput, maxPasswordLength)
+	}
+	if len(scopes) == 0 {
+		scopes = nil
+	}
+
+	// Refuse attempts while the account or the client is delayed or locked out, others count as failed
+	// until the password is known to be right
+	if err := uc.signinGuard.Reserve(ctx, email); err != nil {
+		return nil, err
+	}
+
 	// Read user account in repository
 	user, err := uc.repository.Find(ctx, email)
 	if err != nil {
-		return "", errors.New("invalid email or password")
+		uc.hashService.ComparePassword(password, uc.dummyHash())
+		return nil, errors.New("invalid email or password")
 	}
 
-	fmt.Println(user)
-
 	// Validate password with hashManager
 	match := uc.hashService.ComparePassword(password, user.Password)
 	if !match {
-		return "", errors.New("invalid email or password") // Avoid disclosing password error details
+		uc.auditor.Record(ctx, "", models.AuditSigninFailed, models.AuditTargetUser, user.ID, nil, nil)
+		return nil, errors.New("invalid email or password") // Avoid disclosing password error details
+	}
+	uc.signinGuard.Release(ctx, email)
+
+	if user.Suspended() {
+		return nil, fmt.Errorf("%w: account is suspended", models.ErrForbidden)
+	}
+
+	// The password is known now, upgrade its hash when the hashing parameters changed
+	if uc.hashService.NeedsRehash(user.Password) {
+		if hashedPassword, err := uc.hashService.HashPassword(password); err != nil {
+			uc.logger.ErrorContext(ctx, "failed to rehash password", "user", user, "error", err)
+		} else if err := uc.repository.UpdatePasswordHash(ctx, user.ID, hashedPassword); err != nil {
+			uc.logger.ErrorContext(ctx, "failed to store rehashed password", "user", user, "error", err)
+		}
+	}
+
+	// The lockout is only cleared once the second factor is verified too
+	twoFactor, err := uc.twoFactor.Read(ctx, user.ID)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil && twoFactor.Enabled() {
+		challenge, err := twoFactorChallenge(uc.tokenService, user, scopes)
+		if err != nil {
+			return nil, err
+		}
+		return &models.SigninResult{TwoFactorRequired: true, ChallengeToken: challenge}, nil
 	}
 
 	// Generate authentication token using tokenService
-	token, err := uc.tokenService.GenerateToken(user.ID)
+	token, err := uc.tokenService.GenerateToken(ctx, user, scopes)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	uc.signinGuard.Succeeded(ctx, email)
+	uc.auditor.Record(ctx, user.ID, models.AuditSignin, models.AuditTargetUser, user.ID, nil, nil)
 
-	return token, nil
+	return &models.SigninResult{Token: token}, nil
 }
 
 // GetProfile retrieves a user's profile
 func (uc *UsersUseCase) GetProfile(ctx context.Context, token string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UsersUseCase.GetProfile")
+	defer span.End()
+
 	// Validate token using token manager
-	claims, err := uc.tokenService.ParseToken(token)
+	claims, err := uc.tokenService.ParseToken(ctx, token)
 	if err != nil {
 		return nil, err
 	}
@@ -110,39 +224,143 @@ func (uc *UsersUseCase) GetProfile(ctx context.Context, token string) (*models.U
 	return user, nil
 }
 
-// UpdateProfile updates a user's profile information
-func (uc *UsersUseCase) UpdateProfile(ctx context.Context, token string, user *models.User) error {
+// UpdateProfile applies the given changes to the token user's profile
+func (uc *UsersUseCase) UpdateProfile(ctx context.Context, token string, update *models.ProfileUpdate) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UsersUseCase.UpdateProfile")
+	defer span.End()
+
 	// Validate token and extract user ID
-	claims, err := uc.tokenService.ParseToken(token)
+	claims, err := uc.tokenService.ParseToken(ctx, token)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	userID := claims["user_id"].(string)
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, errors.New("invalid user ID in token")
+	}
+
+	user, err := uc.repository.Read(ctx, userID)
 	if err != nil {
-		return fmt.Errorf("invalid user ID in token: %w", err)
+		return nil, err
+	}
+	before := *user
+
+	if update.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.Bio != nil {
+		user.Bio = strings.TrimSpace(*update.Bio)
+	}
+	if update.AvatarURL != nil {
+		user.AvatarURL = strings.TrimSpace(*update.AvatarURL)
+	}
+	emailChanged := false
+	if update.Email != nil {
+		email, err := normalizeEmail(*update.Email)
+		if err != nil {
+			return nil, err
+		}
+		if email != user.Email {
+			if existing, err := uc.repository.Find(ctx, email); err == nil && existing.ID != user.ID {
+				return nil, fmt.Errorf("%w: email is already in use", models.ErrInvalidInput)
+			}
+			user.Email = email
+			user.EmailVerifiedAt = nil // The new address must be verified again
+			emailChanged = true
+		}
 	}
 
-	// Ensure user is updating their own profile
-	if userID != user.ID {
-		return errors.New("unauthorized to update another user's profile")
+	if err := validateProfile(user); err != nil {
+		return nil, err
 	}
 
 	// Update user information in repository
-	return uc.repository.Update(ctx, userID, user)
+	if err := uc.repository.Update(ctx, userID, user); err != nil {
+		return nil, err
+	}
+	uc.auditor.Record(ctx, userID, models.AuditProfileUpdated, models.AuditTargetUser, userID, before, user)
+
+	if emailChanged {
+		if err := uc.verification.SendVerification(ctx, user); err != nil {
+			uc.logger.ErrorContext(ctx, "failed to send verification email", "user", user, "error", err)
+		}
+	}
+
+	uc.socketService.Broadcast(models.NewUserUpdatedMessage(userID, uc.clockService.Now()))
+
+	return user, nil
 }
 
-// DeleteProfile deletes a user's account
-func (uc *UsersUseCase) DeleteProfile(ctx context.Context, token string, user *models.User) error {
+// DeleteProfile deletes the token user's account once their password is confirmed
+func (uc *UsersUseCase) DeleteProfile(ctx context.Context, token string, password string) error {
+	ctx, span := tracer.Start(ctx, "UsersUseCase.DeleteProfile")
+	defer span.End()
+
 	// Validate token and extract user ID
-	claims, err := uc.tokenService.ParseToken(token)
+	claims, err := uc.tokenService.ParseToken(ctx, token)
 	if err != nil {
 		return err
 	}
-	userID := claims["user_id"].(string)
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return errors.New("invalid user ID in token")
+	}
+
+	user, err := uc.repository.Read(ctx, userID)
 	if err != nil {
-		return fmt.Errorf("invalid user ID in token: %w", err)
+		return err
+	}
+
+	// Require the password again before doing anything irreversible
+	if !uc.hashService.ComparePassword(password, user.Password) {
+		return fmt.Errorf("%w: invalid password", models.ErrUnauthorized)
+	}
+
+	// Delete user from repository, cleaning up their posts
+	postIDs, err := uc.repository.Delete(ctx, userID, uc.deletionPolicy)
+	if err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, userID, models.AuditAccountDeleted, models.AuditTargetUser, userID, user,
+		map[string]any{"policy": uc.deletionPolicy, "post_ids": postIDs})
+
+	for _, postID := range postIDs {
+		uc.socketService.Broadcast(models.NewPostDeletedMessage(postID, userID, uc.clockService.Now()))
+	}
+	uc.socketService.Broadcast(models.NewUserDeletedMessage(userID, uc.clockService.Now()))
+
+	return nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || address.Name != "" {
+		return "", fmt.Errorf("%w: invalid email address", models.ErrInvalidInput)
+	}
+	return strings.ToLower(address.Address), nil
+}
+
+func validateProfile(user *models.User) error {
+	if utf8.RuneCountInString(user.DisplayName) > 64 {
+		return fmt.Errorf("%w: display name must be at most 64 characters", models.ErrInvalidInput)
+	}
+	if utf8.RuneCountInString(user.Bio) > 280 {
+		return fmt.Errorf("%w: bio must be at most 280 characters", models.ErrInvalidInput)
+	}
+	if user.AvatarURL != "" {
+		avatar, err := url.Parse(user.AvatarURL)
+		if err != nil || (avatar.Scheme != "http" && avatar.Scheme != "https") || avatar.Host == "" || len(user.AvatarURL) > 2048 {
+			return fmt.Errorf("%w: avatar URL must be an absolute http or https URL", models.ErrInvalidInput)
+		}
 	}
+	return nil
+}
 
-	// Delete user from repository
-	return uc.repository.Delete(ctx, userID)
+func validateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			return fmt.Errorf("%w: unknown scope %q", models.ErrInvalidInput, scope)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,103 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+const (
+	verifyEmailPurpose = "verify_email"
+	verifyEmailTTL     = 48 * time.Hour
+)
+
+type VerificationUseCase struct {
+	usersRepository interfaces.UsersRepository
+	tokenService    interfaces.TokenService
+	mailerService   interfaces.MailerService
+	clockService    interfaces.ClockService
+	auditor         *Auditor
+	verifyURL       string
+	resendCooldown  time.Duration
+}
+
+// Verification usecases constructor, verifyURL is the page the verification token is appended to
+func NewVerificationUseCase(
+	usersRepository interfaces.UsersRepository,
+	tokenService interfaces.TokenService,
+	mailerService interfaces.MailerService,
+	clockService interfaces.ClockService,
+	auditor *Auditor,
+	verifyURL string,
+	resendCooldown time.Duration,
+) *VerificationUseCase {
+	return &VerificationUseCase{usersRepository, tokenService, mailerService, clockService, auditor, verifyURL, resendCooldown}
+}
+
+// SendVerification emails the user a signed link confirming their current email address
+func (uc *VerificationUseCase) SendVerification(ctx context.Context, user *models.User) error {
+	claims := map[string]any{"user_id": user.ID, "email": user.Email}
+	verificationToken, err := uc.tokenService.GenerateActionToken(verifyEmailPurpose, claims, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	err = uc.mailerService.Send(ctx, models.Mail{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Confirm your email address by opening this link within %s:\n%s?token=%s",
+			verifyEmailTTL, uc.verifyURL, verificationToken),
+	})
+	if err != nil {
+		return err
+	}
+
+	return uc.usersRepository.UpdateVerificationSentAt(ctx, user.ID, uc.clockService.Now())
+}
+
+// VerifyEmail confirms the email address carried by a verification token
+func (uc *VerificationUseCase) VerifyEmail(ctx context.Context, verificationToken string) error {
+	claims, err := uc.tokenService.ParseActionToken(verifyEmailPurpose, verificationToken)
+	if err != nil {
+		return fmt.Errorf("%w: invalid or expired verification token", models.ErrInvalidInput)
+	}
+	userID, _ := claims["user_id"].(string)
+	email, _ := claims["email"].(string)
+
+	if err := uc.usersRepository.MarkEmailVerified(ctx, userID, email, uc.clockService.Now()); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, userID, models.AuditEmailVerified, models.AuditTargetUser, userID, nil, map[string]string{"email": email})
+
+	return nil
+}
+
+// ResendVerification sends the token user a new verification link, at most once per cooldown
+func (uc *VerificationUseCase) ResendVerification(ctx context.Context, token string) error {
+	claims, err := uc.tokenService.ParseToken(ctx, token)
+	if err != nil {
+		return err
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return errors.New("invalid user ID in token")
+	}
+
+	user, err := uc.usersRepository.Read(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerified() {
+		return fmt.Errorf("%w: email address is already verified", models.ErrInvalidInput)
+	}
+	if user.VerificationSentAt != nil && uc.clockService.Now().Sub(*user.VerificationSentAt) < uc.resendCooldown {
+		return fmt.Errorf("%w: wait before requesting another verification email", models.ErrTooManyRequests)
+	}
+
+	return uc.SendVerification(ctx, user)
+}
@@ -0,0 +1,250 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// minWebhookSecretLength keeps secrets brought by subscribers from being guessed, generated ones are 64 characters
+const minWebhookSecretLength = 32
+
+type WebhooksUseCases struct {
+	repository   interfaces.WebhooksRepository
+	tokenService interfaces.TokenService
+	uuidService  interfaces.UUIDService
+	dispatcher   interfaces.WebhookDispatcher
+	policy       *Policy
+	auditor      *Auditor
+	clockService interfaces.ClockService
+}
+
+// Webhooks usecases constructor
+func NewWebhooksUseCases(repository interfaces.WebhooksRepository, tokenService interfaces.TokenService,
+	uuidService interfaces.UUIDService, dispatcher interfaces.WebhookDispatcher, policy *Policy, auditor *Auditor,
+	clockService interfaces.ClockService) *WebhooksUseCases {
+	return &WebhooksUseCases{repository, tokenService, uuidService, dispatcher, policy, auditor, clockService}
+}
+
+// CreateWebhook subscribes a new webhook for the token's user
+func (uc *WebhooksUseCases) CreateWebhook(ctx context.Context, token string, webhook *models.Webhook) (*models.Webhook, error) {
+	ownerID, err := uc.ownerID(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.policy.Authorize(ctx, ownerID, models.ActionManageWebhooks); err != nil {
+		return nil, err
+	}
+
+	if err := validateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	if webhook.Secret != "" && len(webhook.Secret) < minWebhookSecretLength {
+		return nil, fmt.Errorf("%w: secret must be at least %d characters", models.ErrInvalidInput, minWebhookSecretLength)
+	}
+	if err := uc.dispatcher.CheckURL(ctx, webhook.URL); err != nil {
+		return nil, err
+	}
+
+	id, err := uc.uuidService.GenerateID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	webhook.ID = id
+	webhook.OwnerID = ownerID
+	webhook.Active = true
+
+	// Generate a signing secret unless the subscriber brought its own
+	if webhook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+
+	created, err := uc.repository.Create(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+	uc.auditor.Record(ctx, ownerID, models.AuditWebhookCreated, models.AuditTargetWebhook, created.ID, nil, withoutSecret(created))
+
+	return created, nil
+}
+
+// GetWebhooks lists the webhooks of the token's user
+func (uc *WebhooksUseCases) GetWebhooks(ctx context.Context, token string) ([]*models.Webhook, error) {
+	ownerID, err := uc.ownerID(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := uc.repository.Find(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+// GetWebhookById retrieves a webhook by ID
+func (uc *WebhooksUseCases) GetWebhookById(ctx context.Context, token string, id string) (*models.Webhook, error) {
+	ownerID, err := uc.ownerID(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := uc.repository.Read(ctx, id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+
+	return webhook, nil
+}
+
+// UpdateWebhook changes the url, events or active flag of a webhook
+func (uc *WebhooksUseCases) UpdateWebhook(ctx context.Context, token string, id string, webhook *models.Webhook) (*models.Webhook, error) {
+	ownerID, err := uc.ownerID(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.policy.Authorize(ctx, ownerID, models.ActionManageWebhooks); err != nil {
+		return nil, err
+	}
+
+	if err := validateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	if err := uc.dispatcher.CheckURL(ctx, webhook.URL); err != nil {
+		return nil, err
+	}
+
+	before, err := uc.repository.Read(ctx, id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := uc.repository.Update(ctx, id, ownerID, webhook)
+	if err != nil {
+		return nil, err
+	}
+	updated.Secret = ""
+	uc.auditor.Record(ctx, ownerID, models.AuditWebhookUpdated, models.AuditTargetWebhook, id, withoutSecret(before), updated)
+
+	return updated, nil
+}
+
+// DeleteWebhook deletes a webhook and its delivery log
+func (uc *WebhooksUseCases) DeleteWebhook(ctx context.Context, token string, id string) error {
+	ownerID, err := uc.ownerID(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	before, err := uc.repository.Read(ctx, id, ownerID)
+	if err != nil {
+		return err
+	}
+	if err := uc.repository.Delete(ctx, id, ownerID); err != nil {
+		return err
+	}
+	uc.auditor.Record(ctx, ownerID, models.AuditWebhookDeleted, models.AuditTargetWebhook, id, withoutSecret(before), nil)
+
+	return nil
+}
+
+// GetDeliveries retrieves the delivery log of a webhook with pagination
+func (uc *WebhooksUseCases) GetDeliveries(ctx context.Context, token string, id string, pageNumber int, pageSize int) ([]*models.WebhookDelivery, error) {
+	ownerID, err := uc.ownerID(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure the webhook belongs to the user
+	if _, err := uc.repository.Read(ctx, id, ownerID); err != nil {
+		return nil, err
+	}
+
+	return uc.repository.FindDeliveries(ctx, id, pageNumber, pageSize)
+}
+
+// RedeliverDelivery queues a delivery again, typically one that was dead-lettered
+func (uc *WebhooksUseCases) RedeliverDelivery(ctx context.Context, token string, id string, deliveryId string) (*models.WebhookDelivery, error) {
+	ownerID, err := uc.ownerID(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := uc.repository.Read(ctx, id, ownerID); err != nil {
+		return nil, err
+	}
+
+	delivery, err := uc.repository.ReadDelivery(ctx, deliveryId, id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status == models.DeliveryPending {
+		return nil, fmt.Errorf("%w: delivery is already pending", models.ErrInvalidInput)
+	}
+
+	now := uc.clockService.Now()
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	if err := uc.repository.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	uc.dispatcher.Wake()
+
+	return delivery, nil
+}
+
+func (uc *WebhooksUseCases) ownerID(ctx context.Context, token string) (string, error) {
+	claims, err := uc.tokenService.ParseToken(ctx, token)
+	if err != nil {
+		return "", errors.New("invalid token")
+	}
+	ownerID, ok := claims["user_id"].(string)
+	if !ok {
+		return "", errors.New("invalid user ID in token")
+	}
+	return ownerID, nil
+}
+
+func validateWebhook(webhook *models.Webhook) error {
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", models.ErrInvalidInput)
+	}
+
+	if len(webhook.Events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", models.ErrInvalidInput)
+	}
+	for _, event := range webhook.Events {
+		if event != models.WebhookAllEvents && !slices.Contains(models.EventTypes, event) {
+			return fmt.Errorf("%w: unknown event %q", models.ErrInvalidInput, event)
+		}
+	}
+
+	return nil
+}
+
+// withoutSecret copies a webhook for the audit log, signing secrets are never recorded
+func withoutSecret(webhook *models.Webhook) models.Webhook {
+	copied := *webhook
+	copied.Secret = ""
+	return copied
+}
@@ -2,44 +2,403 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/jdashel/posts-api/internal/infra/logging"
 )
 
-// Config represents application-wide configuration
+// Config represents application-wide configuration. Every setting is named by its path in the
+// configuration file, such as "server.port", which is also its command line flag, and most can be
+// set with the environment variable of their env tag. Settings tagged secret are masked when the
+// configuration is printed.
 type Config struct {
-	DatabaseURL string `json:"database_url"`
-	ServerPort  string `json:"server_port"`
-	SecretKey   string `json:"secret_key"`
+	Server     Server     `json:"server"`
+	Database   Database   `json:"database"`
+	Log        Log        `json:"log"`
+	Tracing    Tracing    `json:"tracing"`
+	Auth       Auth       `json:"auth"`
+	Accounts   Accounts   `json:"accounts"`
+	Mail       Mail       `json:"mail"`
+	Webhooks   Webhooks   `json:"webhooks"`
+	Websocket  Websocket  `json:"websocket"`
+	RateLimits RateLimits `json:"rate_limits"`
 }
 
-// LoadConfig loads configuration from environment variables
-func LoadConfig() (*Config, error) {
-	// Load environment variables from .env file
-	err := godotenv.Load()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load environment variables: %w", err)
+// Server configures the HTTP server
+type Server struct {
+	// Interface the server listens on, empty for every interface
+	Host string `json:"host" env:"SERVER_HOST"`
+	Port int    `json:"port" env:"SERVER_PORT"`
+	// Base URL of the public site, used to build links sent by email, http://localhost:<port> by default
+	PublicURL string `json:"public_url" env:"PUBLIC_URL"`
+	// Address of the separate listener serving /metrics, empty to serve no metrics
+	MetricsAddr string `json:"metrics_addr" env:"METRICS_ADDR"`
+	// Proxies, as IPs or CIDRs, whose X-Forwarded-For header gives the client IP, none when empty
+	TrustedProxies []string `json:"trusted_proxies" env:"TRUSTED_PROXIES"`
+
+	// How long reading the request headers, the whole request, writing its response, and keeping an
+	// idle connection may take
+	ReadHeaderTimeout Duration `json:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	ReadTimeout       Duration `json:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout      Duration `json:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       Duration `json:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	// How long readiness fails before connections are closed on shutdown, for traffic to move away
+	ShutdownDrainDelay Duration `json:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY"`
+	// How long requests in progress and websocket clients are given to finish on shutdown
+	ShutdownTimeout Duration `json:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// How long each readiness check may take before it fails
+	HealthCheckTimeout Duration `json:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+}
+
+// Database configures the PostgreSQL database
+type Database struct {
+	URL string `json:"url" env:"DATABASE_URL" secret:"true"`
+
+	// Connections the pool opens at most, zero for no limit, and keeps idle for the next queries
+	MaxOpenConns int `json:"max_open_conns" env:"DATABASE_MAX_OPEN_CONNS"`
+	MaxIdleConns int `json:"max_idle_conns" env:"DATABASE_MAX_IDLE_CONNS"`
+	// How long a connection is reused, and kept idle, before it is closed, zero for no limit
+	ConnMaxLifetime Duration `json:"conn_max_lifetime" env:"DATABASE_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time" env:"DATABASE_CONN_MAX_IDLE_TIME"`
+	// How long the server keeps trying to reach the database on startup
+	ConnectTimeout Duration `json:"connect_timeout" env:"DATABASE_CONNECT_TIMEOUT"`
+	// How long each query may take before it is canceled
+	QueryTimeout Duration `json:"query_timeout" env:"DATABASE_QUERY_TIMEOUT"`
+	// How many times a query failing with a transient error is retried
+	QueryRetries int `json:"query_retries" env:"DATABASE_QUERY_RETRIES"`
+}
+
+// Log configures the application logs
+type Log struct {
+	// Lowest level logged, "debug", "info", "warn" or "error"
+	Level string `json:"level" env:"LOG_LEVEL"`
+	// Format of log records, "json" or "text"
+	Format string `json:"format" env:"LOG_FORMAT"`
+}
+
+// Tracing configures the export of trace spans
+type Tracing struct {
+	// Where trace spans are sent, "none", "otlp" or "stdout"
+	Exporter string `json:"exporter" env:"TRACING_EXPORTER"`
+	// Share of new traces sampled, between 0 and 1, incoming requests keep their caller's decision
+	SampleRatio float64 `json:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// Auth configures tokens, passwords and the ways users sign in
+type Auth struct {
+	JWT       JWT       `json:"jwt"`
+	Passwords Passwords `json:"passwords"`
+	Signin    Signin    `json:"signin"`
+	TOTP      TOTP      `json:"totp"`
+	// OpenID Connect providers users may sign in with. The OIDC_PROVIDERS variable lists their
+	// names instead, each configured by OIDC_<NAME>_ISSUER, _CLIENT_ID and _CLIENT_SECRET.
+	OIDCProviders []OIDCProvider `json:"oidc_providers"`
+}
+
+// JWT configures the keys signing tokens
+type JWT struct {
+	// Directory of the token signing keys, shared by every instance
+	KeyDir string `json:"key_dir" env:"JWT_KEY_DIR"`
+	// Algorithm of generated signing keys, "EdDSA" or "RS256"
+	Algorithm string `json:"algorithm" env:"JWT_ALGORITHM"`
+	// How often a new signing key is generated, zero disables rotation
+	KeyRotation Duration `json:"key_rotation" env:"JWT_KEY_ROTATION"`
+	// How long retired keys still verify tokens, must exceed the longest token lifetime
+	KeyRetention Duration `json:"key_retention" env:"JWT_KEY_RETENTION"`
+}
+
+// Passwords configures how passwords are hashed and screened
+type Passwords struct {
+	// Algorithm of new password hashes, "argon2id" or "bcrypt", older hashes are upgraded on signin
+	HashAlgorithm string `json:"hash_algorithm" env:"PASSWORD_HASH_ALGORITHM"`
+	Argon2        Argon2 `json:"argon2"`
+	// Cost of bcrypt hashes
+	BcryptCost int `json:"bcrypt_cost" env:"BCRYPT_COST"`
+	// Secret mixed into every password hash, changing it invalidates every password
+	Pepper string `json:"pepper" env:"PASSWORD_PEPPER" secret:"true"`
+
+	// Lowest strength score from 0 to 4 a new password must reach
+	MinScore int `json:"min_score" env:"PASSWORD_MIN_SCORE"`
+	// Corpus of breached password SHA-1 hashes, the bundled one when empty
+	BreachedFile string `json:"breached_file" env:"BREACHED_PASSWORDS_FILE"`
+}
+
+// Argon2 configures argon2id hashes, memory in KiB, passes and threads
+type Argon2 struct {
+	Memory      uint32 `json:"memory" env:"ARGON2_MEMORY"`
+	Iterations  uint32 `json:"iterations" env:"ARGON2_ITERATIONS"`
+	Parallelism uint8  `json:"parallelism" env:"ARGON2_PARALLELISM"`
+}
+
+// Signin configures the delays and lockouts of failed signins
+type Signin struct {
+	// Failed signins after which an account is locked out
+	MaxAccountFailures int `json:"max_account_failures" env:"SIGNIN_MAX_ACCOUNT_FAILURES"`
+	// Failed signins after which a client IP is locked out
+	MaxIPFailures int `json:"max_ip_failures" env:"SIGNIN_MAX_IP_FAILURES"`
+	// Delay after the first failed signin on an account, doubled on each further failure
+	BaseDelay Duration `json:"base_delay" env:"SIGNIN_BASE_DELAY"`
+	// How long lockouts last, and how long failures are remembered
+	LockoutDuration Duration `json:"lockout_duration" env:"SIGNIN_LOCKOUT_DURATION"`
+}
+
+// TOTP configures two-factor authentication codes
+type TOTP struct {
+	// Issuer shown by authenticator apps next to TOTP codes
+	Issuer string `json:"issuer" env:"TOTP_ISSUER"`
+	// Time steps a TOTP code may be early or late by
+	Skew int `json:"skew" env:"TOTP_SKEW"`
+}
+
+// OIDCProvider configures an OpenID Connect provider, named in its login routes
+type OIDCProvider struct {
+	Name         string `json:"name"`
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret" secret:"true"`
+}
+
+// Accounts configures the lifecycle of user accounts
+type Accounts struct {
+	// What happens to a user's posts when their account is deleted, "delete" or "soft_delete"
+	DeletionPolicy string `json:"deletion_policy" env:"ACCOUNT_DELETION_POLICY"`
+	// Actions accounts with an unverified email may still perform, such as "posts:create"
+	UnverifiedActions []string `json:"unverified_actions" env:"UNVERIFIED_ACTIONS"`
+	// Minimum delay between two verification emails
+	VerificationResendCooldown Duration `json:"verification_resend_cooldown" env:"VERIFICATION_RESEND_COOLDOWN"`
+}
+
+// Mail configures outgoing emails
+type Mail struct {
+	// Sender address of outgoing emails
+	From string `json:"from" env:"MAIL_FROM"`
+	// Directory the development mailer writes emails to, they are logged when empty
+	Dir string `json:"dir" env:"MAIL_DIR"`
+}
+
+// Webhooks configures webhook deliveries
+type Webhooks struct {
+	// Lets webhooks target loopback, link-local and private addresses, for local development only
+	AllowPrivateTargets bool `json:"allow_private_targets" env:"WEBHOOKS_ALLOW_PRIVATE_TARGETS"`
+}
+
+// Websocket configures the websocket clients
+type Websocket struct {
+	// Messages that may wait for a slow client before new ones are dropped
+	ClientBuffer int `json:"client_buffer" env:"WEBSOCKET_CLIENT_BUFFER"`
+	// How long writing a message to a client may take before it is disconnected
+	WriteTimeout Duration `json:"write_timeout" env:"WEBSOCKET_WRITE_TIMEOUT"`
+	// Origins browsers may open websockets from, such as "https://example.com", any when empty
+	AllowedOrigins []string `json:"allowed_origins" env:"WEBSOCKET_ALLOWED_ORIGINS"`
+}
+
+// RateLimits configures the rate limits of routes
+type RateLimits struct {
+	// Where rate limit buckets are kept, "memory" or "postgres" to share them between instances
+	Store string `json:"store" env:"RATE_LIMIT_STORE"`
+	// Rate limits by route, keyed by "<METHOD> <route>" such as "POST /posts"
+	Routes RateLimitRoutes `json:"routes" env:"RATE_LIMITS"`
+}
+
+// Default returns the configuration used for every setting left unset
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Host:               "localhost",
+			Port:               8080,
+			MetricsAddr:        "localhost:9090",
+			TrustedProxies:     []string{},
+			ReadHeaderTimeout:  Duration{5 * time.Second},
+			ReadTimeout:        Duration{15 * time.Second},
+			WriteTimeout:       Duration{30 * time.Second},
+			IdleTimeout:        Duration{2 * time.Minute},
+			ShutdownDrainDelay: Duration{5 * time.Second},
+			ShutdownTimeout:    Duration{30 * time.Second},
+			HealthCheckTimeout: Duration{2 * time.Second},
+		},
+		Database: Database{
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: Duration{30 * time.Minute},
+			ConnMaxIdleTime: Duration{5 * time.Minute},
+			ConnectTimeout:  Duration{time.Minute},
+			QueryTimeout:    Duration{5 * time.Second},
+			QueryRetries:    2,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+		Auth: Auth{
+			JWT: JWT{
+				KeyDir:       "keys",
+				Algorithm:    "EdDSA",
+				KeyRotation:  Duration{30 * 24 * time.Hour},
+				KeyRetention: Duration{72 * time.Hour},
+			},
+			Passwords: Passwords{
+				HashAlgorithm: "argon2id",
+				Argon2:        Argon2{Memory: 19456, Iterations: 2, Parallelism: 1},
+				BcryptCost:    12,
+				MinScore:      2,
+			},
+			Signin: Signin{
+				MaxAccountFailures: 5,
+				MaxIPFailures:      50,
+				BaseDelay:          Duration{time.Second},
+				LockoutDuration:    Duration{15 * time.Minute},
+			},
+			TOTP: TOTP{
+				Issuer: "Posts API",
+				Skew:   1,
+			},
+			OIDCProviders: []OIDCProvider{},
+		},
+		Accounts: Accounts{
+			DeletionPolicy:             "delete",
+			UnverifiedActions:          []string{},
+			VerificationResendCooldown: Duration{time.Minute},
+		},
+		Mail: Mail{
+			From: "no-reply@localhost",
+		},
+		Websocket: Websocket{
+			ClientBuffer:   16,
+			WriteTimeout:   Duration{10 * time.Second},
+			AllowedOrigins: []string{},
+		},
+		RateLimits: RateLimits{
+			Store: "memory",
+			Routes: RateLimitRoutes{
+				"POST /posts":  {Requests: 30, Period: time.Minute},
+				"POST /signup": {Requests: 5, Period: time.Hour},
+				"POST /signin": {Requests: 20, Period: time.Minute},
+			},
+		},
 	}
+}
 
-	// Unmarshal configuration from environment variables
-	SERVER_PORT := os.Getenv("SERVER_PORT")
-	SECRET_KEY := os.Getenv("SECRET_KEY")
-	DATABSE_URL := os.Getenv("DATABASE_URL")
+// Addr returns the address the server listens on
+func (s Server) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
 
-	config := &Config{
-		DatabaseURL: DATABSE_URL,
-		ServerPort:  SERVER_PORT,
-		SecretKey:   SECRET_KEY,
+// complete fills the settings whose default depends on others
+func (c *Config) complete() {
+	if c.Server.PublicURL == "" {
+		c.Server.PublicURL = fmt.Sprintf("http://localhost:%d", c.Server.Port)
 	}
+	c.Server.PublicURL = strings.TrimSuffix(c.Server.PublicURL, "/")
+}
 
-	// Validate required configuration values
-	required := []string{"DATABASE_URL", "SERVER_PORT", "SECRET_KEY"}
-	for _, key := range required {
-		if value := os.Getenv(key); value == "" {
-			return nil, fmt.Errorf("%s environment variable is required", key)
+// validate returns every problem of the configuration, each naming the setting at fault
+func (c *Config) validate() []string {
+	problems := []string{}
+	check := func(ok bool, path string, format string, args ...any) {
+		if !ok {
+			problems = append(problems, describe(path)+" "+fmt.Sprintf(format, args...))
 		}
 	}
+	oneOf := func(value string, path string, allowed ...string) {
+		for _, candidate := range allowed {
+			if value == candidate {
+				return
+			}
+		}
+		check(false, path, "must be %s or %s, not %q", strings.Join(allowed[:len(allowed)-1], ", "), allowed[len(allowed)-1],
+			value)
+	}
+
+	check(c.Server.Port >= 1 && c.Server.Port <= 65535, "server.port", "must be between 1 and 65535")
+	publicURL, err := url.Parse(c.Server.PublicURL)
+	check(err == nil && (publicURL.Scheme == "http" || publicURL.Scheme == "https") && publicURL.Host != "",
+		"server.public_url", "must be an absolute http or https URL")
+	if c.Server.MetricsAddr != "" {
+		_, port, err := net.SplitHostPort(c.Server.MetricsAddr)
+		check(err == nil && port != "", "server.metrics_addr", "must be a host:port address")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "server.trusted_proxies", "%q must be an IP or a CIDR", proxy)
+	}
+	check(c.Server.ReadHeaderTimeout.Duration > 0, "server.read_header_timeout", "must be positive")
+	check(c.Server.ReadTimeout.Duration > 0, "server.read_timeout", "must be positive")
+	check(c.Server.ReadHeaderTimeout.Duration <= c.Server.ReadTimeout.Duration, "server.read_header_timeout",
+		"must not exceed server.read_timeout")
+	check(c.Server.WriteTimeout.Duration > 0, "server.write_timeout", "must be positive")
+	check(c.Server.IdleTimeout.Duration > 0, "server.idle_timeout", "must be positive")
+	check(c.Server.ShutdownDrainDelay.Duration >= 0, "server.shutdown_drain_delay", "must not be negative")
+	check(c.Server.ShutdownTimeout.Duration > 0, "server.shutdown_timeout", "must be positive")
+	check(c.Server.HealthCheckTimeout.Duration > 0, "server.health_check_timeout", "must be positive")
+
+	check(c.Database.URL != "", "database.url", "is required")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns", "must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns", "must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns, "database.max_idle_conns",
+		"must not exceed database.max_open_conns")
+	check(c.Database.ConnMaxLifetime.Duration >= 0, "database.conn_max_lifetime", "must not be negative")
+	check(c.Database.ConnMaxIdleTime.Duration >= 0, "database.conn_max_idle_time", "must not be negative")
+	check(c.Database.ConnectTimeout.Duration >= 0, "database.connect_timeout", "must not be negative")
+	check(c.Database.QueryTimeout.Duration > 0, "database.query_timeout", "must be positive")
+	check(c.Database.QueryRetries >= 0, "database.query_retries", "must not be negative")
+
+	_, err = logging.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level", "must be debug, info, warn or error, not %q", c.Log.Level)
+	oneOf(c.Log.Format, "log.format", "json", "text")
+
+	oneOf(c.Tracing.Exporter, "tracing.exporter", "none", "otlp", "stdout")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	oneOf(c.Auth.JWT.Algorithm, "auth.jwt.algorithm", "EdDSA", "RS256")
+	check(c.Auth.JWT.KeyDir != "", "auth.jwt.key_dir", "is required")
+	check(c.Auth.JWT.KeyRotation.Duration >= 0, "auth.jwt.key_rotation", "must not be negative")
+	check(c.Auth.JWT.KeyRetention.Duration > 0, "auth.jwt.key_retention", "must be positive")
+
+	oneOf(c.Auth.Passwords.HashAlgorithm, "auth.passwords.hash_algorithm", "argon2id", "bcrypt")
+	check(c.Auth.Passwords.Argon2.Memory >= 8, "auth.passwords.argon2.memory", "must be at least 8 KiB")
+	check(c.Auth.Passwords.Argon2.Iterations >= 1, "auth.passwords.argon2.iterations", "must be positive")
+	check(c.Auth.Passwords.Argon2.Parallelism >= 1, "auth.passwords.argon2.parallelism", "must be positive")
+	check(c.Auth.Passwords.BcryptCost >= 10 && c.Auth.Passwords.BcryptCost <= 31, "auth.passwords.bcrypt_cost",
+		"must be between 10 and 31")
+	check(c.Auth.Passwords.MinScore >= 0 && c.Auth.Passwords.MinScore <= 4, "auth.passwords.min_score",
+		"must be between 0 and 4")
+
+	check(c.Auth.Signin.MaxAccountFailures >= 1, "auth.signin.max_account_failures", "must be positive")
+	check(c.Auth.Signin.MaxIPFailures >= 1, "auth.signin.max_ip_failures", "must be positive")
+	check(c.Auth.Signin.BaseDelay.Duration >= 0, "auth.signin.base_delay", "must not be negative")
+	check(c.Auth.Signin.LockoutDuration.Duration > 0, "auth.signin.lockout_duration", "must be positive")
+
+	check(c.Auth.TOTP.Skew >= 0, "auth.totp.skew", "must not be negative")
+
+	names := map[string]bool{}
+	for i, provider := range c.Auth.OIDCProviders {
+		path := fmt.Sprintf("auth.oidc_providers[%d]", i)
+		check(provider.Name != "", path+".name", "is required")
+		check(!names[provider.Name], path+".name", "%q is used by another provider", provider.Name)
+		check(provider.Issuer != "", path+".issuer", "is required")
+		check(provider.ClientID != "", path+".client_id", "is required")
+		names[provider.Name] = true
+	}
+
+	oneOf(c.Accounts.DeletionPolicy, "accounts.deletion_policy", "delete", "soft_delete")
+	check(c.Accounts.VerificationResendCooldown.Duration >= 0, "accounts.verification_resend_cooldown",
+		"must not be negative")
+
+	check(c.Websocket.ClientBuffer >= 1, "websocket.client_buffer", "must be positive")
+	check(c.Websocket.WriteTimeout.Duration > 0, "websocket.write_timeout", "must be positive")
+
+	oneOf(c.RateLimits.Store, "rate_limits.store", "memory", "postgres")
+	for route, limit := range c.RateLimits.Routes {
+		check(limit.Requests >= 1 && limit.Period > 0, "rate_limits.routes", "%q must allow a positive number of "+
+			"requests per positive period", route)
+	}
 
-	return config, nil
+	return problems
 }
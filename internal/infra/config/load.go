@@ -0,0 +1,363 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError lists every problem found while loading the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// setting is a value of the configuration that can be set from a string
+type setting struct {
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// LoadConfig loads the configuration in layers, each overriding the previous ones: the defaults,
+// the YAML or TOML file given by the -config flag or CONFIG_FILE, environment variables, also
+// read from a .env file when there is one, and command line flags such as -server.port=8080.
+// Every problem found is reported at once in a ValidationError.
+func LoadConfig(args []string) (*Config, error) {
+	config := Default()
+	settings := config.settings()
+
+	// Flags are parsed first to find the file, and applied last
+	flags := flag.NewFlagSet("posts-api", flag.ContinueOnError)
+	file := flags.String("config", "", "configuration file, .yaml, .yml or .toml")
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		usage := "sets " + s.path
+		if s.env != "" {
+			usage += ", overriding $" + s.env
+		}
+		path := s.path
+		flags.Func(path, usage, func(value string) error {
+			flagValues[path] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	// The environment may come from the deployment alone
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env file: %w", err)
+	}
+
+	problems := []string{}
+	if *file == "" {
+		*file = os.Getenv("CONFIG_FILE")
+	}
+	if *file != "" {
+		problems = append(problems, config.loadFile(*file, settings)...)
+	}
+
+	for _, s := range settings {
+		if value := os.Getenv(s.env); s.env != "" && value != "" {
+			if err := s.set(value); err != nil {
+				problems = append(problems, fmt.Sprintf("$%s: %s", s.env, err))
+			}
+		}
+	}
+	if providers := oidcProvidersFromEnv(); providers != nil {
+		config.Auth.OIDCProviders = providers
+	}
+
+	for _, s := range settings {
+		if value, ok := flagValues[s.path]; ok {
+			if err := s.set(value); err != nil {
+				problems = append(problems, fmt.Sprintf("-%s: %s", s.path, err))
+			}
+		}
+	}
+
+	config.complete()
+	problems = append(problems, config.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	return config, nil
+}
+
+// loadFile overrides the configuration with the settings of a YAML or TOML file, unknown
+// settings are reported rather than ignored so typos do not go unnoticed
+func (c *Config) loadFile(path string, settings []setting) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to read configuration file: %s", err)}
+	}
+
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return []string{fmt.Sprintf("%s: configuration files must be .yaml, .yml or .toml", path)}
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s", path, err)}
+	}
+
+	problems := []string{}
+	for _, problem := range checkKeys(values, reflect.TypeOf(*c), "") {
+		problems = append(problems, fmt.Sprintf("%s: %s", path, problem))
+	}
+	for _, s := range settings {
+		if value, ok := lookup(values, s.path); ok {
+			if err := s.setValue(value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s %s", path, s.path, err))
+			}
+		}
+	}
+
+	// Lists of sections are decoded whole
+	if value, ok := lookup(values, "auth.oidc_providers"); ok {
+		providers := []OIDCProvider{}
+		encoded, _ := json.Marshal(value)
+		if err := json.Unmarshal(encoded, &providers); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: auth.oidc_providers must be a list of providers", path))
+		} else {
+			c.Auth.OIDCProviders = providers
+		}
+	}
+	return problems
+}
+
+// checkKeys returns a problem for every key of values matching no setting of t, and every
+// section given a single value
+func checkKeys(values map[string]any, t reflect.Type, prefix string) []string {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		fields[jsonName(t.Field(i))] = t.Field(i)
+	}
+
+	problems := []string{}
+	for key, value := range values {
+		field, ok := fields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown setting %s%s", prefix, key))
+			continue
+		}
+		if field.Type.Kind() == reflect.Slice && isSection(field.Type.Elem()) {
+			list, _ := value.([]any)
+			for i, item := range list {
+				if nested, ok := item.(map[string]any); ok {
+					problems = append(problems, checkKeys(nested, field.Type.Elem(), fmt.Sprintf("%s%s[%d].", prefix, key, i))...)
+				}
+			}
+			continue
+		}
+		if !isSection(field.Type) {
+			continue
+		}
+		nested, ok := value.(map[string]any)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s%s must be a section", prefix, key))
+			continue
+		}
+		problems = append(problems, checkKeys(nested, field.Type, prefix+key+".")...)
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// lookup finds the value of a setting path such as "server.port" in the values of a file
+func lookup(values map[string]any, path string) (any, bool) {
+	section, rest, nested := strings.Cut(path, ".")
+	value, ok := values[section]
+	if !ok || !nested {
+		return value, ok
+	}
+	if nestedValues, ok := value.(map[string]any); ok {
+		return lookup(nestedValues, rest)
+	}
+	return nil, false
+}
+
+// oidcProvidersFromEnv reads the providers named by OIDC_PROVIDERS, nil when it is unset
+func oidcProvidersFromEnv() []OIDCProvider {
+	names := os.Getenv("OIDC_PROVIDERS")
+	if names == "" {
+		return nil
+	}
+
+	providers := []OIDCProvider{}
+	for _, name := range splitList(names) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// settings returns the settings of the configuration that are set from strings, the fields of
+// sections and lists of sections excluded
+func (c *Config) settings() []setting {
+	settings := []setting{}
+	var walk func(value reflect.Value, prefix string)
+	walk = func(value reflect.Value, prefix string) {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			path := prefix + jsonName(field)
+			switch {
+			case isSection(field.Type):
+				walk(value.Field(i), path+".")
+			case field.Type.Kind() == reflect.Slice && isSection(field.Type.Elem()):
+			default:
+				settings = append(settings, setting{
+					path:   path,
+					env:    field.Tag.Get("env"),
+					secret: field.Tag.Get("secret") == "true",
+					value:  value.Field(i),
+				})
+			}
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return settings
+}
+
+// setValue sets a value decoded from a file, written as it would be in a variable
+func (s setting) setValue(value any) error {
+	switch value := value.(type) {
+	case string:
+		return s.set(value)
+	case bool, int, int64, uint64, float64:
+		return s.set(fmt.Sprint(value))
+	case []any:
+		if s.value.Kind() != reflect.Slice {
+			break
+		}
+		items := []string{}
+		for _, item := range value {
+			if _, ok := item.(map[string]any); ok {
+				return fmt.Errorf("must be a list of values")
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		return s.set(strings.Join(items, ","))
+	case map[string]any:
+		if s.value.Kind() != reflect.Map {
+			break
+		}
+		items := []string{}
+		for key, item := range value {
+			items = append(items, fmt.Sprintf("%s=%v", key, item))
+		}
+		return s.set(strings.Join(items, ","))
+	}
+	return fmt.Errorf("must be a %s", typeName(s.value.Type()))
+}
+
+// set parses a value from a variable or a flag
+func (s setting) set(text string) error {
+	if unmarshaler, ok := s.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(text))
+	}
+
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := strconv.ParseInt(strings.TrimSpace(text), 10, s.value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer, not %q", text)
+		}
+		s.value.SetInt(number)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, err := strconv.ParseUint(strings.TrimSpace(text), 10, s.value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer between 0 and %d, not %q", uint64(1)<<s.value.Type().Bits()-1, text)
+		}
+		s.value.SetUint(number)
+	case reflect.Float32, reflect.Float64:
+		number, err := strconv.ParseFloat(strings.TrimSpace(text), s.value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a number, not %q", text)
+		}
+		s.value.SetFloat(number)
+	case reflect.Bool:
+		boolean, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return fmt.Errorf("must be true or false, not %q", text)
+		}
+		s.value.SetBool(boolean)
+	case reflect.Slice:
+		s.value.Set(reflect.ValueOf(splitList(text)))
+	default:
+		return fmt.Errorf("cannot be set from text")
+	}
+	return nil
+}
+
+// describe names a setting in problems, with its environment variable when it has one
+func describe(path string) string {
+	for _, s := range Default().settings() {
+		if s.path == path && s.env != "" {
+			return fmt.Sprintf("%s ($%s)", path, s.env)
+		}
+	}
+	return path
+}
+
+// isSection tells whether t groups settings rather than being one
+func isSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(Duration{})
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "whole number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "section"
+	}
+	return t.Kind().String()
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// masked replaces the value of secrets when the configuration is printed
+const masked = "********"
+
+// Print writes the configuration as YAML, laid out like the configuration file, with secrets
+// masked. URLs keep everything but their password.
+func (c *Config) Print(w io.Writer) error {
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	// JSON is YAML, decoding it to a node keeps the order of the settings
+	var document yaml.Node
+	if err := yaml.Unmarshal(encoded, &document); err != nil {
+		return err
+	}
+	secrets := map[string]bool{}
+	secretPaths(reflect.TypeOf(*c), "", secrets)
+	maskNode(&document, "", secrets)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// secretPaths collects the paths of the settings tagged secret, items of lists marked "[]"
+func secretPaths(t reflect.Type, prefix string, paths map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		path := prefix + jsonName(field)
+		switch {
+		case isSection(field.Type):
+			secretPaths(field.Type, path+".", paths)
+		case field.Type.Kind() == reflect.Slice && isSection(field.Type.Elem()):
+			secretPaths(field.Type.Elem(), path+"[].", paths)
+		case field.Tag.Get("secret") == "true":
+			paths[path] = true
+		}
+	}
+}
+
+// maskNode masks the secrets under node, and lays it out in block style
+func maskNode(node *yaml.Node, path string, secrets map[string]bool) {
+	node.Style = 0
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			maskNode(child, path, secrets)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			node.Content[i].Style = 0
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			maskNode(node.Content[i+1], key, secrets)
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			maskNode(child, path+"[]", secrets)
+		}
+	case yaml.ScalarNode:
+		if secrets[path] && node.Value != "" {
+			node.Value = maskSecret(node.Value)
+		}
+	}
+}
+
+// maskSecret masks a secret, or only the password of a URL
+func maskSecret(value string) string {
+	if parsed, err := url.Parse(value); err == nil && parsed.User != nil {
+		if _, ok := parsed.User.Password(); ok {
+			return parsed.Redacted()
+		}
+	}
+	return masked
+}
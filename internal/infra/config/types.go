@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// Duration is a time.Duration written like "15s" or "1h30m" in files, variables and flags
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("must be a duration such as \"30s\" or \"5m\"")
+	}
+	d.Duration = duration
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(formatDuration(d.Duration)), nil
+}
+
+// formatDuration writes a duration without its zero minutes and seconds, "2m" rather than "2m0s"
+func formatDuration(duration time.Duration) string {
+	text := duration.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// RateLimitRoutes are rate limits keyed by "<METHOD> <route>" such as "POST /posts", each written
+// "<requests>/<period>" such as "30/1m". Variables and flags list them as "POST /posts=30/1m, ...".
+type RateLimitRoutes map[string]models.RateLimit
+
+func (r *RateLimitRoutes) UnmarshalText(text []byte) error {
+	limits, err := parseRateLimits(string(text))
+	if err != nil {
+		return err
+	}
+	*r = limits
+	return nil
+}
+
+func (r RateLimitRoutes) MarshalJSON() ([]byte, error) {
+	rules := map[string]string{}
+	for route, limit := range r {
+		rules[route] = strconv.Itoa(limit.Requests) + "/" + formatDuration(limit.Period)
+	}
+	return json.Marshal(rules)
+}
+
+func (r RateLimitRoutes) String() string {
+	items := []string{}
+	for route, limit := range r {
+		items = append(items, route+"="+strconv.Itoa(limit.Requests)+"/"+formatDuration(limit.Period))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ", ")
+}
+
+// parseRateLimits parses a comma separated list of "<METHOD> <route>=<requests>/<period>"
+// such as "POST /posts=30/1m"
+func parseRateLimits(value string) (map[string]models.RateLimit, error) {
+	limits := map[string]models.RateLimit{}
+	for _, item := range splitList(value) {
+		route, rule, ok := strings.Cut(item, "=")
+		requests, period, ok2 := strings.Cut(rule, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%q must look like \"POST /posts=30/1m\"", item)
+		}
+
+		limit := models.RateLimit{}
+		var err error
+		if limit.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil || limit.Requests < 1 {
+			return nil, fmt.Errorf("%q must allow a positive number of requests", item)
+		}
+		if limit.Period, err = time.ParseDuration(strings.TrimSpace(period)); err != nil || limit.Period <= 0 {
+			return nil, fmt.Errorf("%q must have a positive period", item)
+		}
+		limits[strings.Join(strings.Fields(route), " ")] = limit
+	}
+	return limits, nil
+}
+
+// splitList splits a comma separated list, ignoring blank entries
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -2,24 +2,83 @@ package database
 
 import (
 	"context"
-	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq" // Register PostgreSQL driver
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Connect establishes a connection to the database
-func Connect(ctx context.Context, url string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", url)
+// SchemaVersion is the version of up.sql the code expects, readiness fails until the database has it
+const SchemaVersion = 1
+
+const (
+	connectBackoff    = 250 * time.Millisecond
+	maxConnectBackoff = 5 * time.Second
+)
+
+// Options tunes the connection pool and how queries are bounded and retried
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// How long to keep trying to reach the database, zero for a single attempt
+	ConnectTimeout time.Duration
+	QueryTimeout   time.Duration
+	QueryRetries   int
+}
+
+// Connect establishes a connection to the database, retrying with backoff while it is not up yet.
+// Every query made within a traced context gets a span carrying its statement, queries of
+// background work outside any trace are not traced.
+func Connect(ctx context.Context, url string, options Options, logger *slog.Logger) (*DB, error) {
+	logger = logger.With("component", "database")
+
+	db, err := otelsql.Open("postgres", url,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	db.SetMaxOpenConns(options.MaxOpenConns)
+	db.SetMaxIdleConns(options.MaxIdleConns)
+	db.SetConnMaxLifetime(options.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(options.ConnMaxIdleTime)
 
-	// Check connection health
-	err = db.PingContext(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	// Check connection health, the database may still be starting alongside the server
+	deadline := time.Now().Add(options.ConnectTimeout)
+	for attempt := 0; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			break
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			db.Close()
+			return nil, fmt.Errorf("failed to ping database: %w", err)
+		}
+		// The last attempt is made when the connect timeout ends
+		delay := min(backoff(attempt, connectBackoff, maxConnectBackoff), remaining)
+		logger.WarnContext(ctx, "database is not reachable yet", "error", err, "attempt", attempt+1, "retry_in", delay)
+		if err := sleep(ctx, delay); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to ping database: %w", err)
+		}
 	}
 
-	return db, nil
+	return &DB{DB: db, queryTimeout: options.QueryTimeout, retries: options.QueryRetries}, nil
 }
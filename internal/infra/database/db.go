@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	retryBackoff    = 50 * time.Millisecond
+	maxRetryBackoff = time.Second
+)
+
+// DB is the connection pool. Queries are run through Do or DoIdempotent, which bound each attempt
+// by the query timeout and retry the attempts failing with transient errors.
+type DB struct {
+	*sql.DB
+	queryTimeout time.Duration
+	retries      int
+}
+
+// Do runs the queries of fn, which must use the context it is given and read its rows before
+// returning. It is retried only when the database aborted it, so it may write anything.
+func (db *DB) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.run(ctx, false, fn)
+}
+
+// DoIdempotent runs the queries of fn like Do, also retrying them when the connection was lost,
+// which may happen after they took effect. fn must give the same result when run twice.
+func (db *DB) DoIdempotent(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.run(ctx, true, fn)
+}
+
+func (db *DB) run(ctx context.Context, idempotent bool, fn func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := db.attempt(ctx, fn)
+		retry := isAborted(err) || idempotent && isConnectionLost(err)
+		if !retry || attempt >= db.retries {
+			return err
+		}
+
+		delay := backoff(attempt, retryBackoff, maxRetryBackoff)
+		trace.SpanFromContext(ctx).AddEvent("retrying query", trace.WithAttributes(
+			attribute.Int("db.attempt", attempt+1),
+			attribute.String("error", err.Error()),
+		))
+		if sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// attempt runs fn within the query timeout, reporting timeouts and transient errors as
+// models.ErrUnavailable
+func (db *DB) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	queryCtx, cancel := context.WithTimeout(ctx, db.queryTimeout)
+	defer cancel()
+
+	err := fn(queryCtx)
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() == nil && errors.Is(queryCtx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: query timed out after %s: %w", models.ErrUnavailable, db.queryTimeout, err)
+	case IsTransient(err):
+		return fmt.Errorf("%w: %w", models.ErrUnavailable, err)
+	}
+	return err
+}
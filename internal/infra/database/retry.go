@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// IsTransient tells whether an operation failing with err may succeed when retried
+func IsTransient(err error) bool {
+	return isAborted(err) || isConnectionLost(err)
+}
+
+// isAborted tells whether the database rolled the operation back because it conflicted with
+// another, a serialization failure or a deadlock, so it had no effect
+func isAborted(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
+
+// isConnectionLost tells whether the connection failed or was reset, the operation may or may not
+// have had an effect
+func isConnectionLost(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Connection exceptions, and the server shutting down or starting up
+		return pqErr.Code.Class() == "08" || pqErr.Code == "57P01" || pqErr.Code == "57P02" || pqErr.Code == "57P03"
+	}
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
+
+// backoff returns how long to wait before retrying attempt, doubling from base up to limit, with
+// jitter so that instances retrying together spread out
+func backoff(attempt int, base time.Duration, limit time.Duration) time.Duration {
+	delay := limit
+	if attempt < 30 && base<<attempt < limit {
+		delay = base << attempt
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// sleep waits for delay, or until ctx is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+)
+
+// ChangeRoleRequest represents the data required to change a user's role
+type ChangeRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type AdminHandler struct {
+	usecases interfaces.AdminUseCase
+}
+
+func NewAdminHandler(usecases interfaces.AdminUseCase) *AdminHandler {
+	return &AdminHandler{usecases}
+}
+
+// ChangeRoleHandler handles role assignments
+func (ah *AdminHandler) ChangeRoleHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		var roleData ChangeRoleRequest
+		if err := c.BindJSON(&roleData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := ah.usecases.ChangeRole(c.Request.Context(), token, c.Param("id"), roleData.Role); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// SearchUsersHandler handles user searches by email or display name
+func (ah *AdminHandler) SearchUsersHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		pageNumber, pageSize, ok := pagination(c)
+		if !ok {
+			return
+		}
+
+		users, err := ah.usecases.SearchUsers(c.Request.Context(), token, c.Query("q"), pageNumber, pageSize)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, users)
+	}
+}
+
+// GetUserHandler handles requests for any user's account
+func (ah *AdminHandler) GetUserHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		user, err := ah.usecases.GetUser(c.Request.Context(), token, c.Param("id"))
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// SuspendUserHandler handles user suspensions
+func (ah *AdminHandler) SuspendUserHandler() gin.HandlerFunc {
+	return ah.setUserSuspended(true)
+}
+
+// UnsuspendUserHandler handles lifting user suspensions
+func (ah *AdminHandler) UnsuspendUserHandler() gin.HandlerFunc {
+	return ah.setUserSuspended(false)
+}
+
+func (ah *AdminHandler) setUserSuspended(suspended bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		if err := ah.usecases.SetUserSuspended(c.Request.Context(), token, c.Param("id"), suspended); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ForcePasswordResetHandler handles forced password resets
+func (ah *AdminHandler) ForcePasswordResetHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		if err := ah.usecases.ForcePasswordReset(c.Request.Context(), token, c.Param("id")); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// GetPostsHandler handles listing every post, optionally filtered by author
+func (ah *AdminHandler) GetPostsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		pageNumber, pageSize, ok := pagination(c)
+		if !ok {
+			return
+		}
+
+		posts, err := ah.usecases.GetPosts(c.Request.Context(), token, c.Query("author_id"), pageNumber, pageSize)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, posts)
+	}
+}
+
+// DeletePostHandler handles permanently deleting any post
+func (ah *AdminHandler) DeletePostHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		if err := ah.usecases.DeletePost(c.Request.Context(), token, c.Param("id")); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// CreateAPIKeyRequest represents the data required to create an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes" validate:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeysHandler struct {
+	usecases interfaces.APIKeysUseCase
+}
+
+func NewAPIKeysHandler(usecases interfaces.APIKeysUseCase) *APIKeysHandler {
+	return &APIKeysHandler{usecases}
+}
+
+// CreateAPIKeyHandler handles API key creation, answering with the key once
+func (ah *APIKeysHandler) CreateAPIKeyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		var keyData CreateAPIKeyRequest
+		if err := c.BindJSON(&keyData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		key := &models.APIKey{Name: keyData.Name, Scopes: keyData.Scopes, ExpiresAt: keyData.ExpiresAt}
+		created, apiKey, err := ah.usecases.CreateAPIKey(c.Request.Context(), token, key)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"api_key": created, "key": apiKey})
+	}
+}
+
+// GetAPIKeysHandler handles listing the user's API keys
+func (ah *APIKeysHandler) GetAPIKeysHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		keys, err := ah.usecases.GetAPIKeys(c.Request.Context(), token)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+// RevokeAPIKeyHandler handles API key revocations
+func (ah *APIKeysHandler) RevokeAPIKeyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		if err := ah.usecases.RevokeAPIKey(c.Request.Context(), token, c.Param("id")); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
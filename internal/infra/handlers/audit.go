@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+type AuditHandler struct {
+	usecases interfaces.AuditUseCase
+}
+
+func NewAuditHandler(usecases interfaces.AuditUseCase) *AuditHandler {
+	return &AuditHandler{usecases}
+}
+
+// GetEntriesHandler handles audit log queries
+func (ah *AuditHandler) GetEntriesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		filter, ok := auditFilter(c)
+		if !ok {
+			return
+		}
+		pageNumber, pageSize, ok := pagination(c)
+		if !ok {
+			return
+		}
+
+		entries, err := ah.usecases.GetEntries(c.Request.Context(), token, filter, pageNumber, pageSize)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// ExportEntriesHandler streams the audit entries matching the query as JSON Lines
+func (ah *AuditHandler) ExportEntriesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		filter, ok := auditFilter(c)
+		if !ok {
+			return
+		}
+
+		// Errors can only be reported as a status until the first line is written
+		writer := &lazyWriter{c: c}
+		if err := ah.usecases.ExportEntries(c.Request.Context(), token, filter, writer); err != nil {
+			if !writer.started {
+				c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			}
+			return
+		}
+		if !writer.started {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+	}
+}
+
+// lazyWriter sends the export headers on the first write
+type lazyWriter struct {
+	c       *gin.Context
+	started bool
+}
+
+func (w *lazyWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", "application/x-ndjson")
+		w.c.Status(http.StatusOK)
+	}
+	n, err := w.c.Writer.Write(p)
+	w.c.Writer.Flush()
+	return n, err
+}
+
+// auditFilter reads the audit filters from the query, answering 400 when a date is invalid
+func auditFilter(c *gin.Context) (models.AuditFilter, bool) {
+	filter := models.AuditFilter{
+		ActorID:    c.Query("actor_id"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+
+	for param, field := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " date, expected RFC 3339"})
+			return filter, false
+		}
+		at = at.UTC()
+		*field = &at
+	}
+
+	return filter, true
+}
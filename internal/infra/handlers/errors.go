@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// errorStatus maps a use case error to its HTTP status code
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, models.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrTooManyRequests):
+		return http.StatusTooManyRequests
+	case errors.Is(err, models.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// retryAfter sets the Retry-After header when the error tells when to retry
+func retryAfter(c *gin.Context, err error) {
+	var retryErr *models.RetryError
+	if errors.As(err, &retryErr) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryErr.RetryAfter.Seconds()))))
+	}
+}
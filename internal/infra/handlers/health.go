@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+type HealthHandler struct {
+	usecases interfaces.HealthUseCase
+}
+
+func NewHealthHandler(usecases interfaces.HealthUseCase) *HealthHandler {
+	return &HealthHandler{usecases}
+}
+
+// LiveHandler answers the liveness probe
+func (hh *HealthHandler) LiveHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondHealth(c, hh.usecases.Live(c.Request.Context()))
+	}
+}
+
+// ReadyHandler answers the readiness probe, with the result of every check
+func (hh *HealthHandler) ReadyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondHealth(c, hh.usecases.Ready(c.Request.Context()))
+	}
+}
+
+// respondHealth fails the probe with 503 when a check failed
+func respondHealth(c *gin.Context, health *models.Health) {
+	c.Header("Cache-Control", "no-store")
+	if !health.Passed() {
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+	c.JSON(http.StatusOK, health)
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+)
+
+type KeysHandler struct {
+	keysService interfaces.KeysService
+}
+
+func NewKeysHandler(keysService interfaces.KeysService) *KeysHandler {
+	return &KeysHandler{keysService}
+}
+
+// JWKSHandler publishes the token verification keys
+func (kh *KeysHandler) JWKSHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Verifiers should refetch often enough to pick up rotated keys
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, kh.keysService.JWKS())
+	}
+}
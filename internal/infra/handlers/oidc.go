@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+)
+
+// oidcFlowCookie keeps the login flow token between the redirect to the provider and its callback
+const oidcFlowCookie = "oidc_flow"
+
+type OIDCHandler struct {
+	usecases interfaces.OIDCUseCase
+}
+
+func NewOIDCHandler(usecases interfaces.OIDCUseCase) *OIDCHandler {
+	return &OIDCHandler{usecases}
+}
+
+// LoginHandler redirects the user to the identity provider
+func (oh *OIDCHandler) LoginHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authURL, flowToken, err := oh.usecases.BeginLogin(c.Request.Context(), c.Param("provider"))
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		// Lax so the cookie comes back with the provider's top-level redirect
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oidcFlowCookie, flowToken, 600, "/auth/", "", secureRequest(c), true)
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// CallbackHandler completes the login when the identity provider redirects the user back
+func (oh *OIDCHandler) CallbackHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if providerError := c.Query("error"); providerError != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": providerError, "description": c.Query("error_description")})
+			return
+		}
+
+		flowToken, err := c.Cookie(oidcFlowCookie)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing login flow, start again"})
+			return
+		}
+		c.SetCookie(oidcFlowCookie, "", -1, "/auth/", "", secureRequest(c), true)
+
+		result, err := oh.usecases.CompleteLogin(c.Request.Context(), c.Param("provider"), flowToken, c.Query("state"), c.Query("code"))
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
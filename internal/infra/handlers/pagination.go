@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pagination reads the page and size query parameters, answering 400 when they are invalid
+func pagination(c *gin.Context) (pageNumber int, pageSize int, ok bool) {
+	pageNumber, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || pageNumber < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
+		return 0, 0, false
+	}
+	pageSize, err = strconv.Atoi(c.DefaultQuery("size", "10"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page size"})
+		return 0, 0, false
+	}
+	return pageNumber, pageSize, true
+}
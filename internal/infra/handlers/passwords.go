@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+)
+
+// ChangePasswordRequest represents the data required to change a password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+// ForgotPasswordRequest represents the data required to request a reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents the data required to reset a password
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+type PasswordsHandler struct {
+	usecases interfaces.PasswordsUseCase
+}
+
+func NewPasswordsHandler(usecases interfaces.PasswordsUseCase) *PasswordsHandler {
+	return &PasswordsHandler{usecases}
+}
+
+// ChangePasswordHandler handles password changes of the signed-in user
+func (ph *PasswordsHandler) ChangePasswordHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var changeData ChangePasswordRequest
+		if err := c.BindJSON(&changeData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		newToken, err := ph.usecases.ChangePassword(c.Request.Context(), token, changeData.OldPassword, changeData.NewPassword)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"token": newToken})
+	}
+}
+
+// ForgotPasswordHandler handles reset link requests, always answering the same way
+func (ph *PasswordsHandler) ForgotPasswordHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var forgotData ForgotPasswordRequest
+		if err := c.BindJSON(&forgotData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := ph.usecases.ForgotPassword(c.Request.Context(), forgotData.Email); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a reset link has been sent"})
+	}
+}
+
+// ResetPasswordHandler handles password resets with a reset token
+func (ph *PasswordsHandler) ResetPasswordHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var resetData ResetPasswordRequest
+		if err := c.BindJSON(&resetData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := ph.usecases.ResetPassword(c.Request.Context(), resetData.Token, resetData.NewPassword); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
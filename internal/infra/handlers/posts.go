@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jdashel/posts-api/internal/domain/interfaces"
@@ -38,7 +37,7 @@ func (h *PostsHandlers) CreatePost(c *gin.Context) {
 	// Create the post using the use case
 	createdPost, err := h.useCases.CreatePost(c.Request.Context(), token, &post)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -55,23 +54,15 @@ func (h *PostsHandlers) GetAllPosts(c *gin.Context) {
 	}
 
 	// Get pagination parameters from query string
-	pageNumber, err := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if err != nil {
-		// Handle invalid pageNumber
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
-		return
-	}
-	pageSize, err := strconv.Atoi(c.DefaultQuery("size", "10"))
-	if err != nil {
-		// Handle invalid pageSize
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page size"})
+	pageNumber, pageSize, ok := pagination(c)
+	if !ok {
 		return
 	}
 
 	// Fetch posts with pagination from the use case
 	posts, err := h.useCases.GetAllPosts(c.Request.Context(), token, pageNumber, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -90,7 +81,7 @@ func (h *PostsHandlers) GetPostById(c *gin.Context) {
 	id := c.Param("id")
 	post, err := h.useCases.GetPostById(c.Request.Context(), token, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -118,9 +109,9 @@ func (h *PostsHandlers) UpdatePost(c *gin.Context) {
 		return
 	}
 
-	post, err := h.useCases.UpdatePost(c.Request.Context(), id, token, &updatedPost)
+	post, err := h.useCases.UpdatePost(c.Request.Context(), token, id, &updatedPost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -137,9 +128,35 @@ func (h *PostsHandlers) DeletePost(c *gin.Context) {
 	}
 	id := c.Param("id")
 	if err := h.useCases.DeletePost(c.Request.Context(), token, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusNoContent, gin.H{})
 }
+
+// HidePost hides a post, for moderators
+func (h *PostsHandlers) HidePost(c *gin.Context) {
+	h.setPostHidden(c, true)
+}
+
+// UnhidePost reverts HidePost
+func (h *PostsHandlers) UnhidePost(c *gin.Context) {
+	h.setPostHidden(c, false)
+}
+
+func (h *PostsHandlers) setPostHidden(c *gin.Context, hidden bool) {
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	post, err := h.useCases.SetPostHidden(c.Request.Context(), token, c.Param("id"), hidden)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
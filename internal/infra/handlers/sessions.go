@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+)
+
+type SessionsHandler struct {
+	usecases interfaces.SessionsUseCase
+}
+
+func NewSessionsHandler(usecases interfaces.SessionsUseCase) *SessionsHandler {
+	return &SessionsHandler{usecases}
+}
+
+// GetSessionsHandler handles listing the user's active sessions
+func (sh *SessionsHandler) GetSessionsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		sessions, err := sh.usecases.GetSessions(c.Request.Context(), token)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, sessions)
+	}
+}
+
+// RevokeSessionHandler handles logging out of a session
+func (sh *SessionsHandler) RevokeSessionHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		if err := sh.usecases.RevokeSession(c.Request.Context(), token, c.Param("id")); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// RevokeOtherSessionsHandler handles logging out everywhere else
+func (sh *SessionsHandler) RevokeOtherSessionsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		if err := sh.usecases.RevokeOtherSessions(c.Request.Context(), token); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
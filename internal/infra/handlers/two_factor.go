@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+)
+
+// TwoFactorCodeRequest carries a TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// DisableTwoFactorRequest carries the password and code confirming two-factor authentication is turned off
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// TwoFactorSigninRequest carries the challenge token returned by signin and a code
+type TwoFactorSigninRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+type TwoFactorHandler struct {
+	usecases interfaces.TwoFactorUseCase
+}
+
+func NewTwoFactorHandler(usecases interfaces.TwoFactorUseCase) *TwoFactorHandler {
+	return &TwoFactorHandler{usecases}
+}
+
+// EnrollHandler handles starting a TOTP enrolment
+func (th *TwoFactorHandler) EnrollHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		enrollment, err := th.usecases.Enroll(c.Request.Context(), token)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, enrollment)
+	}
+}
+
+// EnableHandler handles confirming a TOTP enrolment, answering with the recovery codes once
+func (th *TwoFactorHandler) EnableHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		var codeData TwoFactorCodeRequest
+		if err := c.BindJSON(&codeData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		recoveryCodes, err := th.usecases.Enable(c.Request.Context(), token, codeData.Code)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+	}
+}
+
+// DisableHandler handles turning two-factor authentication off
+func (th *TwoFactorHandler) DisableHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+
+		var disableData DisableTwoFactorRequest
+		if err := c.BindJSON(&disableData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := th.usecases.Disable(c.Request.Context(), token, disableData.Password, disableData.Code); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// SigninHandler handles the second step of a signin
+func (th *TwoFactorHandler) SigninHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var signinData TwoFactorSigninRequest
+		if err := c.BindJSON(&signinData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		token, err := th.usecases.CompleteSignin(c.Request.Context(), signinData.ChallengeToken, signinData.Code)
+		if err != nil {
+			retryAfter(c, err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"token": token})
+	}
+}
@@ -1,16 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/models"
 	"github.com/jdashel/posts-api/internal/domain/usecases"
 )
 
 // SignupRequest represents the data required for a user signup request
 type SignRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	// Scopes optionally narrows the token returned by signin
+	Scopes []string `json:"scopes"`
+}
+
+// DeleteProfileRequest carries the password confirming an account deletion
+type DeleteProfileRequest struct {
+	Password string `json:"password" validate:"required"`
 }
 
 type UsersHandler struct {
@@ -33,7 +42,7 @@ func (uh *UsersHandler) SignupHandler() gin.HandlerFunc {
 
 		token, err := uh.usecases.Signup(c.Request.Context(), signupData.Email, signupData.Password)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
@@ -51,13 +60,17 @@ func (uh *UsersHandler) SigninHandler() gin.HandlerFunc {
 			return
 		}
 
-		token, err := uh.usecases.Signin(c.Request.Context(), signinData.Email, signinData.Password)
-		if err != nil {
+		result, err := uh.usecases.Signin(c.Request.Context(), signinData.Email, signinData.Password, signinData.Scopes)
+		if errors.Is(err, models.ErrTooManyRequests) || errors.Is(err, models.ErrInvalidInput) || errors.Is(err, models.ErrForbidden) {
+			retryAfter(c, err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"token": token})
+		c.JSON(http.StatusOK, result)
 	}
 }
 
@@ -82,3 +95,52 @@ func (uh *UsersHandler) ProfileHandler() gin.HandlerFunc {
 		c.JSON(http.StatusOK, profile)
 	}
 }
+
+// UpdateProfileHandler handles partial profile updates
+func (uh *UsersHandler) UpdateProfileHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var update models.ProfileUpdate
+		if err := c.BindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		profile, err := uh.usecases.UpdateProfile(c.Request.Context(), token, &update)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, profile)
+	}
+}
+
+// DeleteProfileHandler handles account deletion requests
+func (uh *UsersHandler) DeleteProfileHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var deleteData DeleteProfileRequest
+		if err := c.BindJSON(&deleteData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := uh.usecases.DeleteProfile(c.Request.Context(), token, deleteData.Password); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+)
+
+// VerifyEmailRequest carries the token from a verification link
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type VerificationHandler struct {
+	usecases interfaces.VerificationUseCase
+}
+
+func NewVerificationHandler(usecases interfaces.VerificationUseCase) *VerificationHandler {
+	return &VerificationHandler{usecases}
+}
+
+// VerifyEmailHandler handles email confirmations
+func (vh *VerificationHandler) VerifyEmailHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var verifyData VerifyEmailRequest
+		if err := c.BindJSON(&verifyData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := vh.usecases.VerifyEmail(c.Request.Context(), verifyData.Token); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ResendVerificationHandler handles requests for a new verification email
+func (vh *VerificationHandler) ResendVerificationHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if err := vh.usecases.ResendVerification(c.Request.Context(), token); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
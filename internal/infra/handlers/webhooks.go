@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// WebhooksHandlers handles requests related to webhook subscriptions
+type WebhooksHandlers struct {
+	useCases interfaces.WebhooksUseCase
+}
+
+// NewWebhooksHandlers creates a new WebhooksHandlers instance
+func NewWebhooksHandlers(useCases interfaces.WebhooksUseCase) WebhooksHandlers {
+	return WebhooksHandlers{useCases: useCases}
+}
+
+// CreateWebhook subscribes a new webhook, returning its signing secret once
+func (h *WebhooksHandlers) CreateWebhook(c *gin.Context) {
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var webhook models.Webhook
+	if err := c.BindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := h.useCases.CreateWebhook(c.Request.Context(), token, &webhook)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetWebhooks lists the user's webhooks
+func (h *WebhooksHandlers) GetWebhooks(c *gin.Context) {
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	webhooks, err := h.useCases.GetWebhooks(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhookById retrieves a webhook by its ID
+func (h *WebhooksHandlers) GetWebhookById(c *gin.Context) {
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	webhook, err := h.useCases.GetWebhookById(c.Request.Context(), token, c.Param("id"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook replaces the url, events and active flag of a webhook
+func (h *WebhooksHandlers) UpdateWebhook(c *gin.Context) {
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var webhook models.Webhook
+	if err := c.BindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.useCases.UpdateWebhook(c.Request.Context(), token, c.Param("id"), &webhook)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteWebhook deletes a webhook by its ID
+func (h *WebhooksHandlers) DeleteWebhook(c *gin.Context) {
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.useCases.DeleteWebhook(c.Request.Context(), token, c.Param("id")); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetDeliveries retrieves the delivery log of a webhook with pagination
+func (h *WebhooksHandlers) GetDeliveries(c *gin.Context) {
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	pageNumber, pageSize, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.useCases.GetDeliveries(c.Request.Context(), token, c.Param("id"), pageNumber, pageSize)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverDelivery queues a past delivery again
+func (h *WebhooksHandlers) RedeliverDelivery(c *gin.Context) {
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	delivery, err := h.useCases.RedeliverDelivery(c.Request.Context(), token, c.Param("id"), c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
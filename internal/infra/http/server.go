@@ -2,58 +2,241 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	nethttp "net/http"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
 	"github.com/jdashel/posts-api/internal/domain/usecases"
 	"github.com/jdashel/posts-api/internal/infra/config"
 	"github.com/jdashel/posts-api/internal/infra/database"
 	"github.com/jdashel/posts-api/internal/infra/handlers"
+	"github.com/jdashel/posts-api/internal/infra/logging"
+	"github.com/jdashel/posts-api/internal/infra/middlewares"
 	"github.com/jdashel/posts-api/internal/infra/repositories"
 	"github.com/jdashel/posts-api/internal/infra/services"
+	"github.com/jdashel/posts-api/internal/infra/tracing"
 )
 
-// StartServer starts the HTTP server
-func StartServer(ctx context.Context) error {
-	config, err := config.LoadConfig()
+// tracesFlushTimeout bounds how long pending spans are sent for once the server stopped
+const tracesFlushTimeout = 5 * time.Second
+
+// StartServer starts the HTTP server and serves until ctx is cancelled, then shuts down gracefully:
+// readiness fails for the drain delay, requests in progress are finished, websocket clients are
+// sent a close frame, background work is stopped and the database pool is closed
+func StartServer(ctx context.Context, config *config.Config) error {
+	// Validated with the configuration
+	logLevel, _ := logging.ParseLevel(config.Log.Level)
+	logger := logging.New(os.Stderr, config.Log.Format, logLevel)
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(ctx, config.Tracing.Exporter, config.Tracing.SampleRatio)
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return fmt.Errorf("failed to set up tracing: %w", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracesFlushTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("failed to flush traces", "error", err)
+		}
+	}()
 
-	db, err := database.Connect(ctx, config.DatabaseURL)
+	db, err := database.Connect(ctx, config.Database.URL, database.Options{
+		MaxOpenConns:    config.Database.MaxOpenConns,
+		MaxIdleConns:    config.Database.MaxIdleConns,
+		ConnMaxLifetime: config.Database.ConnMaxLifetime.Duration,
+		ConnMaxIdleTime: config.Database.ConnMaxIdleTime.Duration,
+		ConnectTimeout:  config.Database.ConnectTimeout.Duration,
+		QueryTimeout:    config.Database.QueryTimeout.Duration,
+		QueryRetries:    config.Database.QueryRetries,
+	}, logger)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			logger.Error("failed to close database", "error", err)
+		}
+	}()
 
 	// Websocket
-	socketService := services.NewGorillaSocketService()
+	socketService := services.NewGorillaSocketService(logger, config.Websocket.ClientBuffer,
+		config.Websocket.WriteTimeout.Duration, config.Websocket.AllowedOrigins)
+
+	// Metrics
+	metricsService := services.NewMetricsService()
+	metricsService.RegisterDB(db.DB)
+	metricsService.RegisterHub(socketService.Hub)
 
 	// Repositories injection
 	postsRepository := repositories.NewPostsRepository(db)
 	usersRepository := repositories.NewUsersRepository(db)
+	webhooksRepository := repositories.NewWebhooksRepository(db)
+	passwordResetsRepository := repositories.NewPasswordResetsRepository(db)
+	auditRepository := repositories.NewAuditRepository(db)
+	signinAttemptsRepository := repositories.NewSigninAttemptsRepository(db)
+	identitiesRepository := repositories.NewIdentitiesRepository(db)
+	apiKeysRepository := repositories.NewAPIKeysRepository(db)
+	twoFactorRepository := repositories.NewTwoFactorRepository(db)
+	sessionsRepository := repositories.NewSessionsRepository(db)
+	healthRepository := repositories.NewHealthRepository(db)
 
 	// Services injection
-	hashService := services.NewHashService()
-	tokenService := services.NewTokenService(config.SecretKey)
+	hashService := services.NewHashService(config.Auth.Passwords.HashAlgorithm, services.Argon2Params{
+		Memory:      config.Auth.Passwords.Argon2.Memory,
+		Iterations:  config.Auth.Passwords.Argon2.Iterations,
+		Parallelism: config.Auth.Passwords.Argon2.Parallelism,
+	}, config.Auth.Passwords.BcryptCost, config.Auth.Passwords.Pepper)
+	keyRing, err := services.NewKeyRing(config.Auth.JWT.KeyDir, config.Auth.JWT.Algorithm, config.Auth.JWT.KeyRotation.Duration,
+		config.Auth.JWT.KeyRetention.Duration, logger)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
 	idService := services.NewUUIDService()
+	clockService := services.NewClockService()
+	// API keys are accepted wherever a token is
+	tokenService := services.NewAPIKeyTokenService(
+		services.NewTokenService(keyRing, sessionsRepository, idService, clockService, logger),
+		apiKeysRepository, usersRepository, clockService, logger)
+	breachedPasswordsService, err := services.NewBreachedPasswordsService(config.Auth.Passwords.BreachedFile)
+	if err != nil {
+		return fmt.Errorf("failed to load breached passwords: %w", err)
+	}
+	passwordStrengthService := services.NewPasswordStrengthService()
+	totpService := services.NewTOTPService(config.Auth.TOTP.Issuer, config.Auth.TOTP.Skew)
+	mailerService := services.NewFileMailerService(config.Mail.From, config.Mail.Dir, logger)
+	webhookDispatcher := services.NewWebhookDispatcher(webhooksRepository, idService, logger,
+		config.Webhooks.AllowPrivateTargets)
+	eventsService := services.NewEventsService(socketService, webhookDispatcher)
+	identityProviders := map[string]interfaces.IdentityProvider{}
+	for _, provider := range config.Auth.OIDCProviders {
+		identityProviders[provider.Name] = services.NewOIDCProvider(provider.Issuer, provider.ClientID, provider.ClientSecret,
+			config.Server.PublicURL+"/auth/"+provider.Name+"/callback")
+	}
 
 	// Usecases injections
-	postsUsecases := usecases.NewPostsUseCases(postsRepository, tokenService, idService, socketService)
-	usersUsecases := usecases.NewUsersUseCase(usersRepository, hashService, tokenService, idService)
+	policy := usecases.NewPolicy(usersRepository, config.Accounts.UnverifiedActions)
+	auditor := usecases.NewAuditor(auditRepository, idService, clockService, logger)
+	passwordScreen := usecases.NewPasswordScreen(breachedPasswordsService, passwordStrengthService, config.Auth.Passwords.MinScore)
+	signinGuard := usecases.NewSigninGuard(signinAttemptsRepository, clockService, config.Auth.Signin.MaxAccountFailures,
+		config.Auth.Signin.MaxIPFailures, config.Auth.Signin.BaseDelay.Duration, config.Auth.Signin.LockoutDuration.Duration,
+		logger)
+	verificationUsecases := usecases.NewVerificationUseCase(usersRepository, tokenService, mailerService, clockService, auditor,
+		config.Server.PublicURL+"/verify-email", config.Accounts.VerificationResendCooldown.Duration)
+	postsUsecases := usecases.NewPostsUseCases(postsRepository, tokenService, idService, eventsService, policy, auditor,
+		metricsService, clockService)
+	usersUsecases := usecases.NewUsersUseCase(usersRepository, hashService, tokenService, idService, eventsService,
+		verificationUsecases, models.DeletionPolicy(config.Accounts.DeletionPolicy), auditor, signinGuard, twoFactorRepository,
+		passwordScreen, logger, metricsService, clockService)
+	webhooksUsecases := usecases.NewWebhooksUseCases(webhooksRepository, tokenService, idService, webhookDispatcher, policy, auditor,
+		clockService)
+	passwordsUsecases := usecases.NewPasswordsUseCase(usersRepository, passwordResetsRepository, hashService, tokenService,
+		idService, mailerService, clockService, auditor, signinGuard, passwordScreen, config.Server.PublicURL+"/reset-password",
+		sessionsRepository, logger)
+	adminUsecases := usecases.NewAdminUseCase(usersRepository, postsRepository, hashService, tokenService, eventsService,
+		clockService, passwordsUsecases, policy, auditor, sessionsRepository)
+	auditUsecases := usecases.NewAuditUseCase(auditRepository, tokenService, policy)
+	oidcUsecases := usecases.NewOIDCUseCase(usersRepository, identitiesRepository, identityProviders, hashService, tokenService,
+		idService, clockService, auditor, twoFactorRepository)
+	twoFactorUsecases := usecases.NewTwoFactorUseCase(usersRepository, twoFactorRepository, totpService, hashService, tokenService,
+		idService, clockService, signinGuard, auditor)
+	apiKeysUsecases := usecases.NewAPIKeysUseCase(apiKeysRepository, tokenService, idService, clockService, auditor)
+	sessionsUsecases := usecases.NewSessionsUseCase(sessionsRepository, tokenService, clockService, auditor)
+	healthUsecases := usecases.NewHealthUseCase(healthRepository, socketService, database.SchemaVersion,
+		config.Server.HealthCheckTimeout.Duration)
 
 	// Handlers injection
 	websocketHandler := handlers.NewWebsocketHandler(socketService)
 	usersHandler := handlers.NewUsersHandler(usersUsecases)
 	postsHandler := handlers.NewPostsHandlers(postsUsecases)
+	webhooksHandler := handlers.NewWebhooksHandlers(webhooksUsecases)
+	passwordsHandler := handlers.NewPasswordsHandler(passwordsUsecases)
+	verificationHandler := handlers.NewVerificationHandler(verificationUsecases)
+	adminHandler := handlers.NewAdminHandler(adminUsecases)
+	auditHandler := handlers.NewAuditHandler(auditUsecases)
+	keysHandler := handlers.NewKeysHandler(keyRing)
+	oidcHandler := handlers.NewOIDCHandler(oidcUsecases)
+	apiKeysHandler := handlers.NewAPIKeysHandler(apiKeysUsecases)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorUsecases)
+	sessionsHandler := handlers.NewSessionsHandler(sessionsUsecases)
+	healthHandler := handlers.NewHealthHandler(healthUsecases)
 
-	router := gin.Default()
+	// Rate limits are shared between instances when kept in the database, and kept in memory while it is unavailable
+	memoryRateLimitStore := services.NewMemoryRateLimitStore()
+	var rateLimitStore interfaces.RateLimitStore = memoryRateLimitStore
+	if config.RateLimits.Store == "postgres" {
+		rateLimitStore = repositories.NewRateLimitsRepository(db, logger)
+	}
+
+	router := gin.New()
+	// Client IPs, which rate limits and signin lockouts are keyed by, only come from X-Forwarded-For behind a trusted proxy
+	if err := router.SetTrustedProxies(config.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("failed to set trusted proxies: %w", err)
+	}
+	router.Use(gin.Recovery())
+	router.Use(middlewares.RequestID())
+	router.Use(middlewares.Tracing())
+	router.Use(middlewares.AccessLog(logger))
+	router.Use(middlewares.Metrics(metricsService))
+	router.Use(middlewares.RequestInfo())
+	router.Use(middlewares.Authenticate(tokenService))
+	router.Use(middlewares.RateLimit(rateLimitStore, memoryRateLimitStore, tokenService, clockService, config.RateLimits.Routes, logger))
+
+	// Token scopes required by the routes
+	postsRead := middlewares.RequireScope(tokenService, models.ScopePostsRead)
+	postsWrite := middlewares.RequireScope(tokenService, models.ScopePostsWrite)
+	profileRead := middlewares.RequireScope(tokenService, models.ScopeProfileRead)
+	profileWrite := middlewares.RequireScope(tokenService, models.ScopeProfileWrite)
+	webhooksManage := middlewares.RequireScope(tokenService, models.ScopeWebhooksManage)
+	apiKeysManage := middlewares.RequireScope(tokenService, models.ScopeAPIKeysManage)
 
 	// Posts routes
-	router.POST("/posts", postsHandler.CreatePost)
-	router.GET("/posts", postsHandler.GetAllPosts)
-	router.GET("/posts/:id", postsHandler.GetPostById)
-	router.PUT("/posts/:id", postsHandler.UpdatePost)
-	router.DELETE("/posts/:id", postsHandler.DeletePost)
+	router.POST("/posts", postsWrite, postsHandler.CreatePost)
+	router.GET("/posts", postsRead, postsHandler.GetAllPosts)
+	router.GET("/posts/:id", postsRead, postsHandler.GetPostById)
+	router.PUT("/posts/:id", postsWrite, postsHandler.UpdatePost)
+	router.DELETE("/posts/:id", postsWrite, postsHandler.DeletePost)
+
+	// Moderation routes
+	moderate := middlewares.RequireRole(tokenService, models.RoleModerator, models.RoleAdmin)
+	router.POST("/posts/:id/hide", moderate, postsWrite, postsHandler.HidePost)
+	router.POST("/posts/:id/unhide", moderate, postsWrite, postsHandler.UnhidePost)
+
+	// Webhooks routes
+	router.POST("/webhooks", webhooksManage, webhooksHandler.CreateWebhook)
+	router.GET("/webhooks", webhooksManage, webhooksHandler.GetWebhooks)
+	router.GET("/webhooks/:id", webhooksManage, webhooksHandler.GetWebhookById)
+	router.PUT("/webhooks/:id", webhooksManage, webhooksHandler.UpdateWebhook)
+	router.DELETE("/webhooks/:id", webhooksManage, webhooksHandler.DeleteWebhook)
+	router.GET("/webhooks/:id/deliveries", webhooksManage, webhooksHandler.GetDeliveries)
+	router.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", webhooksManage, webhooksHandler.RedeliverDelivery)
+
+	// Admin routes
+	admin := router.Group("/admin", middlewares.RequireRole(tokenService, models.RoleAdmin),
+		middlewares.RequireScope(tokenService, models.ScopeAdmin))
+	admin.GET("/users", adminHandler.SearchUsersHandler())
+	admin.GET("/users/:id", adminHandler.GetUserHandler())
+	admin.PUT("/users/:id/role", adminHandler.ChangeRoleHandler())
+	admin.POST("/users/:id/suspend", adminHandler.SuspendUserHandler())
+	admin.POST("/users/:id/unsuspend", adminHandler.UnsuspendUserHandler())
+	admin.POST("/users/:id/password-reset", adminHandler.ForcePasswordResetHandler())
+	admin.GET("/posts", adminHandler.GetPostsHandler())
+	admin.DELETE("/posts/:id", adminHandler.DeletePostHandler())
+	admin.GET("/audit", auditHandler.GetEntriesHandler())
+	admin.GET("/audit/export", auditHandler.ExportEntriesHandler())
+
+	// Probes of the orchestrator
+	router.GET("/healthz", healthHandler.LiveHandler())
+	router.GET("/readyz", healthHandler.ReadyHandler())
+
+	// Token verification keys
+	router.GET("/.well-known/jwks.json", keysHandler.JWKSHandler())
 
 	// Websocket handler
 	router.GET("/ws", websocketHandler.RequestHandler())
@@ -61,7 +244,102 @@ func StartServer(ctx context.Context) error {
 	// Users routes
 	router.POST("/signup", usersHandler.SignupHandler())
 	router.POST("/signin", usersHandler.SigninHandler())
-	router.GET("/profile", usersHandler.ProfileHandler())
+	router.POST("/signin/2fa", twoFactorHandler.SigninHandler())
+	router.GET("/auth/:provider/login", oidcHandler.LoginHandler())
+	router.GET("/auth/:provider/callback", oidcHandler.CallbackHandler())
+	router.GET("/profile", profileRead, usersHandler.ProfileHandler())
+	router.PATCH("/profile", profileWrite, usersHandler.UpdateProfileHandler())
+	router.DELETE("/profile", profileWrite, usersHandler.DeleteProfileHandler())
+
+	// Two-factor authentication routes
+	router.POST("/profile/2fa/enroll", profileWrite, twoFactorHandler.EnrollHandler())
+	router.POST("/profile/2fa/enable", profileWrite, twoFactorHandler.EnableHandler())
+	router.POST("/profile/2fa/disable", profileWrite, twoFactorHandler.DisableHandler())
+
+	// Sessions routes
+	router.GET("/sessions", profileRead, sessionsHandler.GetSessionsHandler())
+	router.DELETE("/sessions/others", profileWrite, sessionsHandler.RevokeOtherSessionsHandler())
+	router.DELETE("/sessions/:id", profileWrite, sessionsHandler.RevokeSessionHandler())
 
-	return router.Run("localhost:" + config.ServerPort)
+	// API keys routes
+	router.POST("/api-keys", apiKeysManage, apiKeysHandler.CreateAPIKeyHandler())
+	router.GET("/api-keys", apiKeysManage, apiKeysHandler.GetAPIKeysHandler())
+	router.DELETE("/api-keys/:id", apiKeysManage, apiKeysHandler.RevokeAPIKeyHandler())
+
+	// Passwords routes
+	router.POST("/profile/password", profileWrite, passwordsHandler.ChangePasswordHandler())
+	router.POST("/password/forgot", passwordsHandler.ForgotPasswordHandler())
+	router.POST("/password/reset", passwordsHandler.ResetPasswordHandler())
+
+	// Email verification routes
+	router.POST("/verify-email", verificationHandler.VerifyEmailHandler())
+	router.POST("/verify-email/resend", profileWrite, verificationHandler.ResendVerificationHandler())
+
+	server := newServer(config.Server, config.Server.Addr(), router)
+	served := make(chan error, 2)
+	go func() {
+		served <- server.ListenAndServe()
+	}()
+	logger.Info("server listening", "addr", server.Addr)
+
+	// Metrics scraped by Prometheus are served apart from the API, on an address kept private
+	var metricsServer *nethttp.Server
+	if config.Server.MetricsAddr != "" {
+		metrics := nethttp.NewServeMux()
+		metrics.Handle("/metrics", metricsService.Handler())
+		metricsServer = newServer(config.Server, config.Server.MetricsAddr, metrics)
+		go func() {
+			served <- metricsServer.ListenAndServe()
+		}()
+		logger.Info("metrics listening", "addr", metricsServer.Addr)
+	}
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Traffic is moved away before connections are closed
+	healthUsecases.Drain()
+	logger.Info("shutting down", "drain_delay", config.Server.ShutdownDrainDelay.Duration,
+		"timeout", config.Server.ShutdownTimeout.Duration)
+	time.Sleep(config.Server.ShutdownDrainDelay.Duration)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Server.ShutdownTimeout.Duration)
+	defer cancel()
+
+	var errs []error
+	// Websocket connections are hijacked, Shutdown does not wait for them
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to finish requests: %w", err))
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop serving metrics: %w", err))
+		}
+	}
+	if err := socketService.Stop(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to disconnect websocket clients: %w", err))
+	}
+	if err := webhookDispatcher.Stop(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop webhook deliveries: %w", err))
+	}
+	if err := keyRing.Stop(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop key rotation: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// newServer returns a server for handler on addr with the configured timeouts
+func newServer(config config.Server, addr string, handler nethttp.Handler) *nethttp.Server {
+	return &nethttp.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout.Duration,
+		ReadTimeout:       config.ReadTimeout.Duration,
+		WriteTimeout:      config.WriteTimeout.Duration,
+		IdleTimeout:       config.IdleTimeout.Duration,
+	}
 }
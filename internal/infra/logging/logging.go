@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redacted replaces the value of secret attributes
+const redacted = "[REDACTED]"
+
+// secretKeys are the attribute keys whose values are never logged
+var secretKeys = map[string]bool{
+	"password": true, "old_password": true, "new_password": true, "pepper": true, "secret": true, "client_secret": true,
+	"token": true, "access_token": true, "id_token": true, "refresh_token": true, "challenge_token": true,
+	"authorization": true, "cookie": true, "set-cookie": true, "api_key": true, "code": true, "recovery_code": true,
+	"signature": true,
+}
+
+// emailPattern finds email addresses, logged with their local part masked
+var emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// New creates the application logger writing "json" or "text" records at level and above. Records
+// logged with a request context carry its request ID, and its trace and span IDs when traced.
+// Attributes named like secrets are redacted, as are credentials found in values, and email
+// addresses are masked wherever they appear.
+func New(w io.Writer, format string, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	var handler slog.Handler = slog.NewJSONHandler(w, options)
+	if format == "text" {
+		handler = slog.NewTextHandler(w, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// ParseLevel parses a level name such as "debug" or "warn"
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(name))
+	return level, err
+}
+
+// redact rewrites attributes before they are written
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+
+	if attr.Value.Kind() == slog.KindAny {
+		if err, ok := attr.Value.Any().(error); ok {
+			attr.Value = slog.StringValue(err.Error())
+		}
+	}
+	if attr.Value.Kind() != slog.KindString {
+		return attr
+	}
+
+	value := attr.Value.String()
+	if strings.HasPrefix(value, "Bearer ") || strings.HasPrefix(value, models.APIKeyPrefix) {
+		return slog.String(attr.Key, redacted)
+	}
+	if strings.Contains(value, "@") {
+		return slog.String(attr.Key, emailPattern.ReplaceAllString(value, "$1***@$2"))
+	}
+	return attr
+}
+
+// contextHandler adds the request ID and trace carried by the context to records
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := models.RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
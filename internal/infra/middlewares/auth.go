@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// Authenticate verifies the request token once and keeps its claims in the request context,
+// later checks and use cases reuse them. Invalid tokens are left for the handlers to reject.
+func Authenticate(tokenService interfaces.TokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Request.Header.Get("Authorization"); token != "" {
+			if claims, err := tokenService.ParseToken(c.Request.Context(), token); err == nil {
+				c.Request = c.Request.WithContext(models.WithTokenClaims(c.Request.Context(), token, claims))
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose token lacks one of the scopes, naming the missing scope
+func RequireScope(tokenService interfaces.TokenService, scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		claims, err := tokenService.ParseToken(c.Request.Context(), token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		granted := models.ClaimScopes(claims)
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing scope " + scope, "missing_scope": scope})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole rejects requests whose token does not carry one of the roles
+func RequireRole(tokenService interfaces.TokenService, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		claims, err := tokenService.ParseToken(c.Request.Context(), token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		role, _ := claims["role"].(string)
+		if !slices.Contains(roles, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// RequestIDHeader carries the ID correlating the logs of a request
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID restricts the IDs accepted from clients to what is safe to log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,128}$`)
+
+// RequestID stores the request ID in the request context and echoes it in the response. The ID
+// sent by a proxy or client is kept so logs can be correlated across services, otherwise one is
+// generated.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(models.WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+	}
+}
+
+// AccessLog logs every request once it is handled, server errors as errors. The query string
+// is left out since it may carry tokens.
+func AccessLog(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("size", c.Writer.Size()),
+			slog.String("ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		)
+	}
+}
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+)
+
+// Metrics records the count and duration of requests by route pattern, requests matching no
+// route are grouped under "unmatched" so clients cannot create series at will
+func Metrics(metricsService interfaces.MetricsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metricsService.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
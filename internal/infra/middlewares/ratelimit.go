@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// RateLimit limits each route listed in limits, keyed by "<METHOD> <route>" such as "POST /posts",
+// per authenticated user or per client IP for anonymous requests. Requests over the limit are
+// answered 429 with a Retry-After header, and the RateLimit-* headers describe the bucket.
+// While the store is unavailable the buckets are taken from fallback, an in-memory store limiting
+// each instance on its own, so the limits keep holding.
+func RateLimit(store interfaces.RateLimitStore, fallback interfaces.RateLimitStore, tokenService interfaces.TokenService,
+	clockService interfaces.ClockService, limits map[string]models.RateLimit, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		limit, ok := limits[route]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		key := "ip:" + c.ClientIP()
+		if token := c.Request.Header.Get("Authorization"); token != "" {
+			if claims, err := tokenService.ParseToken(c.Request.Context(), token); err == nil {
+				if userID, ok := claims["user_id"].(string); ok {
+					key = "user:" + userID
+				}
+			}
+		}
+
+		result, err := store.Take(c.Request.Context(), key+":"+route, limit, clockService.Now())
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "failed to take rate limit token, falling back to memory", "route", route,
+				"error", err)
+			if result, err = fallback.Take(c.Request.Context(), key+":"+route, limit, clockService.Now()); err != nil {
+				logger.ErrorContext(c.Request.Context(), "failed to take rate limit token", "route", route, "error", err)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
+				return
+			}
+		}
+
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
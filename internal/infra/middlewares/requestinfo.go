@@ -0,0 +1,16 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// RequestInfo stores the client IP and user agent in the request context for the audit log
+func RequestInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info := models.RequestInfo{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+		c.Request = c.Request.WithContext(models.WithRequestInfo(c.Request.Context(), info))
+
+		c.Next()
+	}
+}
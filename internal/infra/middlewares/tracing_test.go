@@ -0,0 +1,116 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/infra/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedRouter records the spans of the requests it serves. GET /posts/:id starts a child span
+// like a use case would, GET /fail answers 500.
+func tracedRouter(t *testing.T) (*gin.Engine, *tracetest.InMemoryExporter) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	exporter := tracing.SetupInMemory()
+
+	router := gin.New()
+	router.Use(RequestID(), Tracing())
+	router.GET("/posts/:id", func(c *gin.Context) {
+		_, span := otel.Tracer("test").Start(c.Request.Context(), "PostsUseCases.GetPostById")
+		span.End()
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+
+	return router, exporter
+}
+
+func serve(router *gin.Engine, request *http.Request) {
+	router.ServeHTTP(httptest.NewRecorder(), request)
+}
+
+// spanNamed returns the recorded span with the given name
+func spanNamed(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %q in %d spans", name, len(exporter.GetSpans()))
+	return tracetest.SpanStub{}
+}
+
+func attributeValue(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingStartsAServerSpanPerRoute(t *testing.T) {
+	router, exporter := tracedRouter(t)
+
+	serve(router, httptest.NewRequest(http.MethodGet, "/posts/42", nil))
+
+	server := spanNamed(t, exporter, "GET /posts/:id")
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("span kind = %s, want server", server.SpanKind)
+	}
+	if route := attributeValue(server, "http.route").AsString(); route != "/posts/:id" {
+		t.Errorf("http.route = %q", route)
+	}
+	if status := attributeValue(server, "http.response.status_code").AsInt64(); status != http.StatusOK {
+		t.Errorf("http.response.status_code = %d", status)
+	}
+	if attributeValue(server, "request.id").AsString() == "" {
+		t.Error("span has no request.id")
+	}
+
+	child := spanNamed(t, exporter, "PostsUseCases.GetPostById")
+	if child.Parent.SpanID() != server.SpanContext.SpanID() || child.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Error("the use case span is not a child of the server span")
+	}
+}
+
+func TestTracingContinuesTheCallersTrace(t *testing.T) {
+	router, exporter := tracedRouter(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/posts/42", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	serve(router, request)
+
+	server := spanNamed(t, exporter, "GET /posts/:id")
+	if server.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the caller's", server.SpanContext.TraceID())
+	}
+	if server.Parent.SpanID().String() != "00f067aa0ba902b7" || !server.Parent.IsRemote() {
+		t.Errorf("parent = %s, want the caller's span", server.Parent.SpanID())
+	}
+}
+
+func TestTracingMarksServerErrors(t *testing.T) {
+	router, exporter := tracedRouter(t)
+
+	serve(router, httptest.NewRequest(http.MethodGet, "/fail", nil))
+	serve(router, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if failed := spanNamed(t, exporter, "GET /fail"); failed.Status.Code != codes.Error {
+		t.Errorf("status = %v, want an error", failed.Status)
+	}
+	if unmatched := spanNamed(t, exporter, "GET unmatched"); unmatched.Status.Code == codes.Error {
+		t.Errorf("a 404 is recorded as an error: %v", unmatched.Status)
+	}
+}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = `id, user_id, name, secret_hash, scopes, last_used_at, expires_at, revoked_at, created_at`
+
+type APIKeysRepository struct {
+	db *database.DB
+}
+
+// APIKeysRepository constructor
+func NewAPIKeysRepository(db *database.DB) *APIKeysRepository {
+	return &APIKeysRepository{db: db}
+}
+
+func scanAPIKey(row scanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.SecretHash, pq.Array(&key.Scopes), &lastUsedAt, &expiresAt, &revokedAt,
+		&key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.LastUsedAt = nullTime(lastUsedAt)
+	key.ExpiresAt = nullTime(expiresAt)
+	key.RevokedAt = nullTime(revokedAt)
+	return &key, nil
+}
+
+// Create stores a new API key
+func (repo *APIKeysRepository) Create(ctx context.Context, key *models.APIKey) (*models.APIKey, error) {
+	stmt := `INSERT INTO api_keys (id, user_id, name, secret_hash, scopes, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + apiKeyColumns
+	var created *models.APIKey
+	err := repo.db.Do(ctx, func(ctx context.Context) (err error) {
+		row := repo.db.QueryRowContext(ctx, stmt, key.ID, key.UserID, key.Name, key.SecretHash, pq.Array(key.Scopes), key.ExpiresAt,
+			key.CreatedAt)
+		created, err = scanAPIKey(row)
+		return err
+	})
+	return created, err
+}
+
+// Read retrieves a key by ID
+func (repo *APIKeysRepository) Read(ctx context.Context, id string) (*models.APIKey, error) {
+	stmt := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+	var key *models.APIKey
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		key, err = scanAPIKey(repo.db.QueryRowContext(ctx, stmt, id))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key %w", models.ErrNotFound)
+	}
+	return key, err
+}
+
+// Find lists the keys of a user, newest first
+func (repo *APIKeysRepository) Find(ctx context.Context, userId string) ([]*models.APIKey, error) {
+	stmt := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
+	var keys []*models.APIKey
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, userId)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		keys = []*models.APIKey{}
+		for rows.Next() {
+			key, err := scanAPIKey(rows)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+
+		return rows.Err()
+	})
+	return keys, err
+}
+
+// Revoke disables a key of a user for good
+func (repo *APIKeysRepository) Revoke(ctx context.Context, id string, userId string, revokedAt time.Time) error {
+	stmt := `UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`
+	affected, err := affectedRows(ctx, repo.db, stmt, revokedAt, id, userId)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("API key %w", models.ErrNotFound)
+	}
+	return nil
+}
+
+// Touch records the last use of a key
+func (repo *APIKeysRepository) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	stmt := `UPDATE api_keys SET last_used_at = $1 WHERE id = $2`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, usedAt, id)
+		return err
+	})
+}
@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
+)
+
+const auditColumns = `id, actor_id, action, target_type, target_id, ip, user_agent, before, after, created_at`
+
+type AuditRepository struct {
+	db *database.DB
+}
+
+// AuditRepository constructor
+func NewAuditRepository(db *database.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func scanAuditEntry(row scanner) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var before, after []byte
+	err := row.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetType, &entry.TargetID, &entry.IP, &entry.UserAgent,
+		&before, &after, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	entry.Before = before
+	entry.After = after
+	return &entry, nil
+}
+
+// auditWhere builds the WHERE clause and arguments of a filter
+func auditWhere(filter models.AuditFilter) (string, []any) {
+	conditions := []string{"TRUE"}
+	args := []any{}
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != "" {
+		add("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		add("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		add("target_id = $%d", filter.TargetID)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// Create appends an entry to the audit log
+func (repo *AuditRepository) Create(ctx context.Context, entry *models.AuditEntry) error {
+	stmt := `INSERT INTO audit_log (id, actor_id, action, target_type, target_id, ip, user_agent, before, after, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	return repo.db.Do(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, entry.ID, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID,
+			entry.IP, entry.UserAgent, nullJSON(entry.Before), nullJSON(entry.After), entry.CreatedAt)
+		return err
+	})
+}
+
+// Find retrieves the entries matching the filter with pagination, newest first
+func (repo *AuditRepository) Find(ctx context.Context, filter models.AuditFilter, pageNumber int, pageSize int) ([]*models.AuditEntry, error) {
+	where, args := auditWhere(filter)
+	offset := (pageNumber - 1) * pageSize
+
+	stmt := fmt.Sprintf(`SELECT %s FROM audit_log WHERE %s ORDER BY created_at DESC OFFSET $%d LIMIT $%d`,
+		auditColumns, where, len(args)+1, len(args)+2)
+
+	var entries []*models.AuditEntry
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		entries = []*models.AuditEntry{}
+		return repo.each(ctx, stmt, append(args, offset, pageSize), func(entry *models.AuditEntry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// Each streams every entry matching the filter, oldest first. The stream may outlast the query
+// timeout and cannot be retried once entries were handed to fn, so it is bounded by ctx alone.
+func (repo *AuditRepository) Each(ctx context.Context, filter models.AuditFilter, fn func(entry *models.AuditEntry) error) error {
+	where, args := auditWhere(filter)
+	stmt := fmt.Sprintf(`SELECT %s FROM audit_log WHERE %s ORDER BY created_at ASC`, auditColumns, where)
+	return repo.each(ctx, stmt, args, fn)
+}
+
+func (repo *AuditRepository) each(ctx context.Context, stmt string, args []any, fn func(entry *models.AuditEntry) error) error {
+	rows, err := repo.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// nullJSON stores empty JSON documents as NULL, others are sent as text since lib/pq encodes []byte as bytea
+func nullJSON(document []byte) any {
+	if len(document) == 0 {
+		return nil
+	}
+	return string(document)
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jdashel/posts-api/internal/infra/database"
+)
+
+// HealthRepository queries the database directly, checks are bounded by their own timeout and
+// report failures rather than retry them
+type HealthRepository struct {
+	db *database.DB
+}
+
+// HealthRepository constructor
+func NewHealthRepository(db *database.DB) *HealthRepository {
+	return &HealthRepository{db: db}
+}
+
+// Ping checks a connection of the pool answers
+func (repo *HealthRepository) Ping(ctx context.Context) error {
+	return repo.db.PingContext(ctx)
+}
+
+// SchemaVersion returns the latest migration applied, zero when there is none
+func (repo *HealthRepository) SchemaVersion(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	if err := repo.db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
+)
+
+type IdentitiesRepository struct {
+	db *database.DB
+}
+
+// IdentitiesRepository constructor
+func NewIdentitiesRepository(db *database.DB) *IdentitiesRepository {
+	return &IdentitiesRepository{db: db}
+}
+
+// Create links an external identity to a user
+func (repo *IdentitiesRepository) Create(ctx context.Context, identity *models.Identity) error {
+	stmt := `INSERT INTO user_identities (provider, subject, user_id, email, created_at) VALUES ($1, $2, $3, $4, $5)`
+	return repo.db.Do(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, identity.Provider, identity.Subject, identity.UserID, identity.Email,
+			identity.CreatedAt)
+		return err
+	})
+}
+
+// Read retrieves the identity of a provider subject
+func (repo *IdentitiesRepository) Read(ctx context.Context, provider string, subject string) (*models.Identity, error) {
+	stmt := `SELECT provider, subject, user_id, email, created_at FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	var identity models.Identity
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		return repo.db.QueryRowContext(ctx, stmt, provider, subject).Scan(&identity.Provider, &identity.Subject, &identity.UserID,
+			&identity.Email, &identity.CreatedAt)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("identity %w", models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
+)
+
+type PasswordResetsRepository struct {
+	db *database.DB
+}
+
+// PasswordResetsRepository constructor
+func NewPasswordResetsRepository(db *database.DB) *PasswordResetsRepository {
+	return &PasswordResetsRepository{db: db}
+}
+
+// Create stores a new reset token hash
+func (repo *PasswordResetsRepository) Create(ctx context.Context, reset *models.PasswordReset) error {
+	stmt := `INSERT INTO password_resets (id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+	return repo.db.Do(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, reset.ID, reset.UserID, reset.TokenHash, reset.ExpiresAt)
+		return err
+	})
+}
+
+// Consume atomically marks a valid reset token as used so it cannot be replayed
+func (repo *PasswordResetsRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (string, error) {
+	stmt := `UPDATE password_resets SET used_at = $2 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2 RETURNING user_id`
+
+	var userID string
+	err := repo.db.Do(ctx, func(ctx context.Context) error {
+		return repo.db.QueryRowContext(ctx, stmt, tokenHash, now).Scan(&userID)
+	})
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: invalid or expired reset token", models.ErrInvalidInput)
+	}
+	return userID, err
+}
+
+// DeleteByUser removes every reset token of a user
+func (repo *PasswordResetsRepository) DeleteByUser(ctx context.Context, userId string) error {
+	stmt := `DELETE FROM password_resets WHERE user_id = $1`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, userId)
+		return err
+	})
+}
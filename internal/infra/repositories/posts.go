@@ -3,100 +3,174 @@ package repositories
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"fmt"
+	"time"
 
 	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
 )
 
+const postColumns = `id, title, content, author_id, hidden_at, created_at, updated_at, deleted_at`
+
 type PostsRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
 // PostsRepository constructor
-func NewPostsRepository(db *sql.DB) *PostsRepository {
+func NewPostsRepository(db *database.DB) *PostsRepository {
 	return &PostsRepository{db: db}
 }
 
-// CreatePost creates a new post in the database
-func (repo *PostsRepository) Create(ctx context.Context, post *models.Post) (*models.Post, error) {
-	stmt := `INSERT INTO posts (id, title, content, author_id) VALUES ($1, $2, $3, $4) RETURNING *`
-	// Use QueryRowContext to retrieve the generated ID
-	row := repo.db.QueryRowContext(ctx, stmt, post.ID, post.Title, post.Content, post.AuthorID)
-
-	var deletedAt sql.NullTime
-	newPost := &models.Post{}
-	err := row.Scan(&newPost.ID, &newPost.Title, &newPost.Content, &newPost.AuthorID, &newPost.CreatedAt, &newPost.UpdatedAt, &deletedAt)
+func scanPost(row scanner) (*models.Post, error) {
+	var post models.Post
+	var hiddenAt, deletedAt sql.NullTime
+	err := row.Scan(&post.ID, &post.Title, &post.Content, &post.AuthorID, &hiddenAt, &post.CreatedAt, &post.UpdatedAt, &deletedAt)
 	if err != nil {
 		return nil, err
 	}
+	post.HiddenAt = nullTime(hiddenAt)
+	post.DeletedAt = deletedAt.Time
+	return &post, nil
+}
 
-	return post, nil
+// CreatePost creates a new post in the database
+func (repo *PostsRepository) Create(ctx context.Context, post *models.Post) (*models.Post, error) {
+	stmt := `INSERT INTO posts (id, title, content, author_id) VALUES ($1, $2, $3, $4) RETURNING ` + postColumns
+	var created *models.Post
+	err := repo.db.Do(ctx, func(ctx context.Context) (err error) {
+		// Use QueryRowContext to retrieve the generated ID
+		created, err = scanPost(repo.db.QueryRowContext(ctx, stmt, post.ID, post.Title, post.Content, post.AuthorID))
+		return err
+	})
+	return created, err
 }
 
 // GetPostById retrieves a post by ID
 func (repo *PostsRepository) Read(ctx context.Context, id string, authorId string) (*models.Post, error) {
-	stmt := `SELECT * FROM posts WHERE id = $1 AND author_id = $2`
-	row := repo.db.QueryRowContext(ctx, stmt, id, authorId)
-
-	var post models.Post
-	var deletedAt sql.NullTime
-	err := row.Scan(&post.ID, &post.Title, &post.Content, &post.AuthorID, &post.CreatedAt, &post.UpdatedAt, &deletedAt)
+	stmt := `SELECT ` + postColumns + ` FROM posts WHERE id = $1 AND author_id = $2 AND deleted_at IS NULL`
+	var post *models.Post
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		post, err = scanPost(repo.db.QueryRowContext(ctx, stmt, id, authorId))
+		return err
+	})
 	if err == sql.ErrNoRows {
-		return nil, errors.New("post not found")
-	} else if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("post %w", models.ErrNotFound)
 	}
-
-	return &post, nil
+	return post, err
 }
 
 // GetAllPosts retrieves posts with pagination
 func (repo *PostsRepository) Find(ctx context.Context, authorId string, pageNumber int, pageSize int) ([]*models.Post, error) {
 	offset := (pageNumber - 1) * pageSize
 
-	stmt := `SELECT * FROM posts WHERE author_id = $1 ORDER BY created_at ASC OFFSET $2 LIMIT $3`
-	rows, err := repo.db.QueryContext(ctx, stmt, authorId, offset, pageSize)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
+	stmt := `SELECT ` + postColumns + ` FROM posts WHERE author_id = $1 AND deleted_at IS NULL ORDER BY created_at ASC OFFSET $2 LIMIT $3`
 	var posts []*models.Post
-	var deletedAt sql.NullTime
-	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.AuthorID, &post.CreatedAt, &post.UpdatedAt, &deletedAt)
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, authorId, offset, pageSize)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		defer rows.Close()
+
+		posts = nil
+		for rows.Next() {
+			post, err := scanPost(rows)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, post)
 		}
-		posts = append(posts, &post)
-	}
 
-	if err := rows.Err(); err != nil {
+		return rows.Err()
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return posts, nil
 }
 
-// UpdatePost updates an existing post
+// FindAll retrieves the posts of every author with pagination, or of one author when authorId is set,
+// hidden and soft-deleted posts included
+func (repo *PostsRepository) FindAll(ctx context.Context, authorId string, pageNumber int, pageSize int) ([]*models.Post, error) {
+	offset := (pageNumber - 1) * pageSize
+
+	stmt := `SELECT ` + postColumns + ` FROM posts WHERE ($1 = '' OR author_id = $1)
+		ORDER BY created_at DESC OFFSET $2 LIMIT $3`
+	var posts []*models.Post
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, authorId, offset, pageSize)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		posts = []*models.Post{}
+		for rows.Next() {
+			post, err := scanPost(rows)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, post)
+		}
+
+		return rows.Err()
+	})
+	return posts, err
+}
+
+// UpdatePost updates an existing post, hidden posts cannot be edited by their author
 func (repo *PostsRepository) Update(ctx context.Context, id string, authorId string, post *models.Post) (*models.Post, error) {
-	stmt := `UPDATE posts SET title = $1, content = $2 WHERE id = $3 AND author_id = $4 RETURNING *`
-	row := repo.db.QueryRowContext(ctx, stmt, post.Title, post.Content, id, authorId)
+	stmt := `UPDATE posts SET title = $1, content = $2, updated_at = NOW()
+		WHERE id = $3 AND author_id = $4 AND hidden_at IS NULL AND deleted_at IS NULL RETURNING ` + postColumns
+	var updatedPost *models.Post
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		updatedPost, err = scanPost(repo.db.QueryRowContext(ctx, stmt, post.Title, post.Content, id, authorId))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("post %w", models.ErrNotFound)
+	}
+	return updatedPost, err
+}
 
-	// Scan updated post data into a new struct to avoid potential conflicts
-	var updatedPost models.Post
-	var deletedAt sql.NullTime
-	if err := row.Scan(&updatedPost.ID, &updatedPost.Title, &updatedPost.Content, &updatedPost.AuthorID, &deletedAt); err != nil {
-		return nil, err
+// SetHidden hides or unhides any post, regardless of its author
+func (repo *PostsRepository) SetHidden(ctx context.Context, id string, hiddenAt *time.Time) (*models.Post, error) {
+	stmt := `UPDATE posts SET hidden_at = $1, updated_at = NOW() WHERE id = $2 AND deleted_at IS NULL RETURNING ` + postColumns
+	var post *models.Post
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		post, err = scanPost(repo.db.QueryRowContext(ctx, stmt, hiddenAt, id))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("post %w", models.ErrNotFound)
 	}
+	return post, err
+}
 
-	return &updatedPost, nil
+// DeleteAny permanently deletes any post, soft-deleted ones included, and returns it
+func (repo *PostsRepository) DeleteAny(ctx context.Context, id string) (*models.Post, error) {
+	stmt := `DELETE FROM posts WHERE id = $1 RETURNING ` + postColumns
+	var post *models.Post
+	err := repo.db.Do(ctx, func(ctx context.Context) (err error) {
+		post, err = scanPost(repo.db.QueryRowContext(ctx, stmt, id))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("post %w", models.ErrNotFound)
+	}
+	return post, err
 }
 
 // DeletePost deletes a post
 func (repo *PostsRepository) Delete(ctx context.Context, id string, authorId string) error {
 	stmt := `DELETE FROM posts WHERE id = $1 AND author_id = $2`
-	_, err := repo.db.ExecContext(ctx, stmt, id, authorId)
-	return err
+	affected, err := affectedRows(ctx, repo.db, stmt, id, authorId)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("post %w", models.ErrNotFound)
+	}
+	return nil
 }
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
+)
+
+const (
+	rateLimitPruneInterval = 10 * time.Minute
+	rateLimitRetention     = 24 * time.Hour // Must exceed the longest rate limit period
+)
+
+// RateLimitsRepository keeps token buckets in the database so limits are shared by every instance
+type RateLimitsRepository struct {
+	db     *database.DB
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// RateLimitsRepository constructor
+func NewRateLimitsRepository(db *database.DB, logger *slog.Logger) *RateLimitsRepository {
+	return &RateLimitsRepository{db: db, logger: logger.With("component", "rate_limits")}
+}
+
+// Take takes a token from the bucket of key, locking its row for the duration of the update
+func (repo *RateLimitsRepository) Take(ctx context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitResult, error) {
+	repo.prune(ctx, now)
+
+	// A replayed take would spend a second token, it is only retried when it was rolled back
+	var result models.RateLimitResult
+	err := repo.db.Do(ctx, func(ctx context.Context) error {
+		tx, err := repo.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var bucket models.TokenBucket
+		var updatedAt sql.NullTime
+		stmt := `SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE`
+		err = tx.QueryRowContext(ctx, stmt, key).Scan(&bucket.Tokens, &updatedAt)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		bucket.UpdatedAt = updatedAt.Time
+
+		result = bucket.Take(limit, now)
+
+		stmt = `INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET tokens = EXCLUDED.tokens, updated_at = EXCLUDED.updated_at`
+		if _, err := tx.ExecContext(ctx, stmt, key, bucket.Tokens, bucket.UpdatedAt); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return models.RateLimitResult{}, err
+	}
+	return result, nil
+}
+
+// prune deletes the buckets idle for long enough to have refilled
+func (repo *RateLimitsRepository) prune(ctx context.Context, now time.Time) {
+	repo.mu.Lock()
+	if now.Sub(repo.lastPrune) < rateLimitPruneInterval {
+		repo.mu.Unlock()
+		return
+	}
+	repo.lastPrune = now
+	repo.mu.Unlock()
+
+	stmt := `DELETE FROM rate_limits WHERE updated_at < $1`
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, now.Add(-rateLimitRetention))
+		return err
+	})
+	if err != nil {
+		repo.logger.ErrorContext(ctx, "failed to prune buckets", "error", err)
+	}
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/infra/database"
+)
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// nullTime converts a nullable column to a time pointer
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// affectedRows runs a statement whose affected rows tell whether it applied, which a retry after
+// a lost connection could not tell, so it is only retried when the database aborted it
+func affectedRows(ctx context.Context, db *database.DB, stmt string, args ...any) (int64, error) {
+	var affected int64
+	err := db.Do(ctx, func(ctx context.Context) error {
+		result, err := db.ExecContext(ctx, stmt, args...)
+		if err != nil {
+			return err
+		}
+		affected, err = result.RowsAffected()
+		return err
+	})
+	return affected, err
+}
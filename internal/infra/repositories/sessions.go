@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
+)
+
+const sessionColumns = `id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at`
+
+type SessionsRepository struct {
+	db *database.DB
+}
+
+// SessionsRepository constructor
+func NewSessionsRepository(db *database.DB) *SessionsRepository {
+	return &SessionsRepository{db: db}
+}
+
+func scanSession(row scanner) (*models.Session, error) {
+	var session models.Session
+	fields, scanned := sessionFields(&session)
+	if err := row.Scan(fields...); err != nil {
+		return nil, err
+	}
+	scanned()
+	return &session, nil
+}
+
+// sessionFields returns the scan destinations of sessionColumns, and completes session once they are scanned
+func sessionFields(session *models.Session) ([]any, func()) {
+	var revokedAt sql.NullTime
+	fields := []any{&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt,
+		&session.ExpiresAt, &revokedAt}
+	return fields, func() {
+		session.RevokedAt = nullTime(revokedAt)
+	}
+}
+
+// Create stores a new session
+func (repo *SessionsRepository) Create(ctx context.Context, session *models.Session) error {
+	stmt := `INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	return repo.db.Do(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, session.ID, session.UserID, session.UserAgent, session.IP, session.CreatedAt,
+			session.LastSeenAt, session.ExpiresAt)
+		return err
+	})
+}
+
+// Read retrieves a session by ID
+func (repo *SessionsRepository) Read(ctx context.Context, id string) (*models.Session, error) {
+	stmt := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+	var session *models.Session
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		session, err = scanSession(repo.db.QueryRowContext(ctx, stmt, id))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session %w", models.ErrNotFound)
+	}
+	return session, err
+}
+
+// ReadWithUser retrieves a session by ID along with its user, as long as the account is not deleted
+func (repo *SessionsRepository) ReadWithUser(ctx context.Context, id string) (*models.Session, *models.User, error) {
+	stmt := `SELECT s.*, u.* FROM (SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1) s
+		JOIN (SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL) u ON u.id = s.user_id`
+	var session models.Session
+	var user models.User
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		sessionDest, sessionScanned := sessionFields(&session)
+		userDest, userScanned := userFields(&user)
+		if err := repo.db.QueryRowContext(ctx, stmt, id).Scan(append(sessionDest, userDest...)...); err != nil {
+			return err
+		}
+		sessionScanned()
+		userScanned()
+		return nil
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("session %w", models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &session, &user, nil
+}
+
+// Find lists the active sessions of a user, most recently seen first. Sessions started before
+// the last password change are left out since their tokens are no longer accepted.
+func (repo *SessionsRepository) Find(ctx context.Context, userId string, now time.Time) ([]*models.Session, error) {
+	stmt := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		AND created_at >= COALESCE((SELECT password_changed_at FROM users WHERE id = $1), created_at)
+		ORDER BY last_seen_at DESC`
+	var sessions []*models.Session
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, userId, now)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		sessions = []*models.Session{}
+		for rows.Next() {
+			session, err := scanSession(rows)
+			if err != nil {
+				return err
+			}
+			sessions = append(sessions, session)
+		}
+
+		return rows.Err()
+	})
+	return sessions, err
+}
+
+// Revoke ends a session of a user
+func (repo *SessionsRepository) Revoke(ctx context.Context, id string, userId string, revokedAt time.Time) error {
+	stmt := `UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`
+	affected, err := affectedRows(ctx, repo.db, stmt, revokedAt, id, userId)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("session %w", models.ErrNotFound)
+	}
+	return nil
+}
+
+// RevokeAll ends every session of a user but one
+func (repo *SessionsRepository) RevokeAll(ctx context.Context, userId string, exceptId string, revokedAt time.Time) error {
+	stmt := `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND id <> $3 AND revoked_at IS NULL`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, revokedAt, userId, exceptId)
+		return err
+	})
+}
+
+// Touch records the last use of a session
+func (repo *SessionsRepository) Touch(ctx context.Context, id string, seenAt time.Time) error {
+	stmt := `UPDATE sessions SET last_seen_at = $1 WHERE id = $2`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, seenAt, id)
+		return err
+	})
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
+	"github.com/lib/pq"
+)
+
+type SigninAttemptsRepository struct {
+	db *database.DB
+}
+
+// SigninAttemptsRepository constructor
+func NewSigninAttemptsRepository(db *database.DB) *SigninAttemptsRepository {
+	return &SigninAttemptsRepository{db: db}
+}
+
+// Find returns the counters of the given keys
+func (repo *SigninAttemptsRepository) Find(ctx context.Context, keys []string) ([]*models.SigninAttempts, error) {
+	stmt := `SELECT key, failures, last_failure_at FROM signin_attempts WHERE key = ANY($1) AND failures > 0`
+	var attempts []*models.SigninAttempts
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, pq.Array(keys))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		attempts = []*models.SigninAttempts{}
+		for rows.Next() {
+			var attempt models.SigninAttempts
+			if err := rows.Scan(&attempt.Key, &attempt.Failures, &attempt.LastFailureAt); err != nil {
+				return err
+			}
+			attempts = append(attempts, &attempt)
+		}
+
+		return rows.Err()
+	})
+	return attempts, err
+}
+
+// Increment atomically counts a failure, unless a concurrent attempt changed the counter since it was seen
+func (repo *SigninAttemptsRepository) Increment(ctx context.Context, key string, seen *models.SigninAttempts, now time.Time,
+	since time.Time) (*models.SigninAttempts, error) {
+	// A counter that was not seen may only exist without failures, or with forgotten ones
+	unchanged := `signin_attempts.failures = 0 OR signin_attempts.last_failure_at < $3`
+	args := []any{key, now, since}
+	if seen != nil {
+		unchanged = `signin_attempts.failures = $4 AND signin_attempts.last_failure_at = $5`
+		args = append(args, seen.Failures, seen.LastFailureAt)
+	}
+	stmt := `INSERT INTO signin_attempts (key, failures, last_failure_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN signin_attempts.last_failure_at < $3 THEN 1 ELSE signin_attempts.failures + 1 END,
+			last_failure_at = $2
+		WHERE ` + unchanged + `
+		RETURNING key, failures, last_failure_at`
+
+	var attempt models.SigninAttempts
+	err := repo.db.Do(ctx, func(ctx context.Context) error {
+		return repo.db.QueryRowContext(ctx, stmt, args...).Scan(&attempt.Key, &attempt.Failures, &attempt.LastFailureAt)
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// Decrement takes back a failure
+func (repo *SigninAttemptsRepository) Decrement(ctx context.Context, key string) error {
+	stmt := `UPDATE signin_attempts SET failures = failures - 1 WHERE key = $1 AND failures > 0`
+	return repo.db.Do(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, key)
+		return err
+	})
+}
+
+// Delete clears the counter of a key
+func (repo *SigninAttemptsRepository) Delete(ctx context.Context, key string) error {
+	stmt := `DELETE FROM signin_attempts WHERE key = $1`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, key)
+		return err
+	})
+}
@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
+)
+
+type TwoFactorRepository struct {
+	db *database.DB
+}
+
+// TwoFactorRepository constructor
+func NewTwoFactorRepository(db *database.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// Read retrieves the enrolment of a user
+func (repo *TwoFactorRepository) Read(ctx context.Context, userId string) (*models.TwoFactor, error) {
+	stmt := `SELECT user_id, secret, enabled_at, last_used_step, created_at FROM two_factor WHERE user_id = $1`
+
+	var twoFactor models.TwoFactor
+	var enabledAt sql.NullTime
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		return repo.db.QueryRowContext(ctx, stmt, userId).Scan(&twoFactor.UserID, &twoFactor.Secret, &enabledAt,
+			&twoFactor.LastUsedStep, &twoFactor.CreatedAt)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("two-factor enrolment %w", models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	twoFactor.EnabledAt = nullTime(enabledAt)
+	return &twoFactor, nil
+}
+
+// Save creates or replaces a pending enrolment, an enabled one is left untouched
+func (repo *TwoFactorRepository) Save(ctx context.Context, twoFactor *models.TwoFactor) error {
+	stmt := `INSERT INTO two_factor (user_id, secret, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at
+		WHERE two_factor.enabled_at IS NULL`
+	affected, err := affectedRows(ctx, repo.db, stmt, twoFactor.UserID, twoFactor.Secret, twoFactor.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: two-factor authentication is already enabled", models.ErrInvalidInput)
+	}
+	return nil
+}
+
+// Enable turns a pending enrolment on, recording the step of the code that confirmed it
+func (repo *TwoFactorRepository) Enable(ctx context.Context, userId string, enabledAt time.Time, step int64) error {
+	stmt := `UPDATE two_factor SET enabled_at = $1, last_used_step = $2 WHERE user_id = $3 AND enabled_at IS NULL`
+	affected, err := affectedRows(ctx, repo.db, stmt, enabledAt, step, userId)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: no pending two-factor enrolment", models.ErrInvalidInput)
+	}
+	return nil
+}
+
+// UseStep atomically records an accepted step so concurrent requests cannot replay a code
+func (repo *TwoFactorRepository) UseStep(ctx context.Context, userId string, step int64) error {
+	stmt := `UPDATE two_factor SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`
+	affected, err := affectedRows(ctx, repo.db, stmt, step, userId)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: code was already used", models.ErrUnauthorized)
+	}
+	return nil
+}
+
+// Delete removes the enrolment and the recovery codes of a user
+func (repo *TwoFactorRepository) Delete(ctx context.Context, userId string) error {
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		tx, err := repo.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userId); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM two_factor WHERE user_id = $1`, userId); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// ReplaceRecoveryCodes swaps every recovery code of a user for new ones
+func (repo *TwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userId string, codes []*models.RecoveryCode) error {
+	// Replaying the swap leaves the same codes
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		tx, err := repo.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userId); err != nil {
+			return err
+		}
+		for _, code := range codes {
+			stmt := `INSERT INTO recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`
+			if _, err := tx.ExecContext(ctx, stmt, code.ID, userId, code.CodeHash); err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit()
+	})
+}
+
+// FindRecoveryCodes lists the unused recovery codes of a user
+func (repo *TwoFactorRepository) FindRecoveryCodes(ctx context.Context, userId string) ([]*models.RecoveryCode, error) {
+	stmt := `SELECT id, user_id, code_hash FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	var codes []*models.RecoveryCode
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, userId)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		codes = []*models.RecoveryCode{}
+		for rows.Next() {
+			var code models.RecoveryCode
+			if err := rows.Scan(&code.ID, &code.UserID, &code.CodeHash); err != nil {
+				return err
+			}
+			codes = append(codes, &code)
+		}
+
+		return rows.Err()
+	})
+	return codes, err
+}
+
+// UseRecoveryCode atomically marks an unused code as used
+func (repo *TwoFactorRepository) UseRecoveryCode(ctx context.Context, id string, usedAt time.Time) error {
+	stmt := `UPDATE recovery_codes SET used_at = $1 WHERE id = $2 AND used_at IS NULL`
+	affected, err := affectedRows(ctx, repo.db, stmt, usedAt, id)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: recovery code was already used", models.ErrUnauthorized)
+	}
+	return nil
+}
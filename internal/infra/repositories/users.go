@@ -3,79 +3,280 @@ package repositories
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
 )
 
+const userColumns = `id, email, password, display_name, bio, avatar_url, role, email_verified_at, verification_sent_at, password_changed_at,
+	suspended_at, created_at, updated_at, deleted_at`
+
 type UsersRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
 // UsersRepository constructor
-func NewUsersRepository(db *sql.DB) *UsersRepository {
+func NewUsersRepository(db *database.DB) *UsersRepository {
 	return &UsersRepository{db: db}
 }
 
-// Create a new user
-func (repo *UsersRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
-	stmt := `INSERT INTO users (id, email, password) VALUES ($1, $2, $3) RETURNING *`
-	row := repo.db.QueryRowContext(ctx, stmt, user.ID, user.Email, user.Password)
-
-	var newUser models.User
-	var deletedAt sql.NullTime
-	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt, &deletedAt)
-	if err != nil {
+func scanUser(row scanner) (*models.User, error) {
+	var user models.User
+	fields, scanned := userFields(&user)
+	if err := row.Scan(fields...); err != nil {
 		return nil, err
 	}
+	scanned()
+	return &user, nil
+}
 
-	return &newUser, nil
+// userFields returns the scan destinations of userColumns, and completes user once they are scanned
+func userFields(user *models.User) ([]any, func()) {
+	var emailVerifiedAt, verificationSentAt, passwordChangedAt, suspendedAt, deletedAt sql.NullTime
+	fields := []any{&user.ID, &user.Email, &user.Password, &user.DisplayName, &user.Bio, &user.AvatarURL, &user.Role,
+		&emailVerifiedAt, &verificationSentAt, &passwordChangedAt, &suspendedAt, &user.CreatedAt, &user.UpdatedAt, &deletedAt}
+	return fields, func() {
+		user.EmailVerifiedAt = nullTime(emailVerifiedAt)
+		user.VerificationSentAt = nullTime(verificationSentAt)
+		user.PasswordChangedAt = nullTime(passwordChangedAt)
+		user.SuspendedAt = nullTime(suspendedAt)
+		user.DeletedAt = nullTime(deletedAt)
+	}
+}
+
+// Create a new user
+func (repo *UsersRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	stmt := `INSERT INTO users (id, email, password, display_name) VALUES ($1, $2, $3, $4) RETURNING ` + userColumns
+	var created *models.User
+	err := repo.db.Do(ctx, func(ctx context.Context) (err error) {
+		created, err = scanUser(repo.db.QueryRowContext(ctx, stmt, user.ID, user.Email, user.Password, user.DisplayName))
+		return err
+	})
+	return created, err
 }
 
 // Read a user by id
 func (repo *UsersRepository) Read(ctx context.Context, id string) (*models.User, error) {
-	stmt := `SELECT * FROM users WHERE id = $1`
-	row := repo.db.QueryRowContext(ctx, stmt, id)
-
-	var user models.User
-	var deletedAt sql.NullTime
-	err := row.Scan(&user.ID, &user.Password, &user.Email, &user.CreatedAt, &user.UpdatedAt, &deletedAt)
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
+	var user *models.User
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		user, err = scanUser(repo.db.QueryRowContext(ctx, stmt, id))
+		return err
+	})
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
-	} else if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("user %w", models.ErrNotFound)
 	}
-
-	return &user, nil
+	return user, err
 }
 
-// Finda user by email
+// Find a user by email, ignoring case as accounts created before emails were normalized may carry capitals
 func (repo *UsersRepository) Find(ctx context.Context, email string) (*models.User, error) {
-	stmt := `SELECT * FROM users WHERE email = $1`
-	row := repo.db.QueryRowContext(ctx, stmt, email)
-
-	var user models.User
-	var deletedAt sql.NullTime
-	err := row.Scan(&user.ID, &user.Password, &user.Email, &user.CreatedAt, &user.UpdatedAt, &deletedAt)
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL`
+	var user *models.User
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		user, err = scanUser(repo.db.QueryRowContext(ctx, stmt, email))
+		return err
+	})
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
-	} else if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("user %w", models.ErrNotFound)
 	}
-
-	return &user, nil
+	return user, err
 }
 
 // UpdateProfile updates a user's profile information
 func (repo *UsersRepository) Update(ctx context.Context, id string, user *models.User) error {
-	stmt := `UPDATE users SET email = $1 WHERE id = $2`
-	_, err := repo.db.ExecContext(ctx, stmt, user.Email, id)
-	return err
+	stmt := `UPDATE users SET email = $1, display_name = $2, bio = $3, avatar_url = $4, email_verified_at = $5, updated_at = NOW()
+		WHERE id = $6 AND deleted_at IS NULL`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, user.Email, user.DisplayName, user.Bio, user.AvatarURL, user.EmailVerifiedAt, id)
+		return err
+	})
+}
+
+// MarkEmailVerified records that the user confirmed the email, as long as it is still their current one
+func (repo *UsersRepository) MarkEmailVerified(ctx context.Context, id string, email string, verifiedAt time.Time) error {
+	stmt := `UPDATE users SET email_verified_at = $1, updated_at = NOW() WHERE id = $2 AND email = $3 AND deleted_at IS NULL`
+	affected, err := affectedRows(ctx, repo.db, stmt, verifiedAt, id, email)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: email address has changed", models.ErrInvalidInput)
+	}
+	return nil
+}
+
+// UpdateVerificationSentAt records when the last verification email was sent
+func (repo *UsersRepository) UpdateVerificationSentAt(ctx context.Context, id string, sentAt time.Time) error {
+	stmt := `UPDATE users SET verification_sent_at = $1 WHERE id = $2`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, sentAt, id)
+		return err
+	})
 }
 
-// DeleteProfile deletes a user's account
-func (repo *UsersRepository) Delete(ctx context.Context, id string) error {
-	stmt := `DELETE FROM users WHERE id = $1`
-	_, err := repo.db.ExecContext(ctx, stmt, id)
-	return err
+// Search finds users whose email or display name contains the query, suspended ones included
+func (repo *UsersRepository) Search(ctx context.Context, query string, pageNumber int, pageSize int) ([]*models.User, error) {
+	offset := (pageNumber - 1) * pageSize
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL AND (email ILIKE $1 OR display_name ILIKE $1)
+		ORDER BY created_at ASC OFFSET $2 LIMIT $3`
+	var users []*models.User
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, pattern, offset, pageSize)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		users = []*models.User{}
+		for rows.Next() {
+			user, err := scanUser(rows)
+			if err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+
+		return rows.Err()
+	})
+	return users, err
+}
+
+// SetSuspended suspends a user, or lifts the suspension when suspendedAt is nil
+func (repo *UsersRepository) SetSuspended(ctx context.Context, id string, suspendedAt *time.Time) error {
+	stmt := `UPDATE users SET suspended_at = $1, updated_at = NOW() WHERE id = $2 AND deleted_at IS NULL`
+	affected, err := affectedRows(ctx, repo.db, stmt, suspendedAt, id)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("user %w", models.ErrNotFound)
+	}
+	return nil
+}
+
+// UpdateRole changes the role of a user, refusing to demote the last active admin
+func (repo *UsersRepository) UpdateRole(ctx context.Context, id string, role string) error {
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		tx, err := repo.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// Locking the admins serializes concurrent demotions, which could otherwise each see another admin left
+		rows, err := tx.QueryContext(ctx,
+			`SELECT id FROM users WHERE role = $1 AND deleted_at IS NULL AND suspended_at IS NULL FOR UPDATE`, models.RoleAdmin)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		admins := []string{}
+		for rows.Next() {
+			var adminID string
+			if err := rows.Scan(&adminID); err != nil {
+				return err
+			}
+			admins = append(admins, adminID)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if role != models.RoleAdmin && len(admins) == 1 && admins[0] == id {
+			return fmt.Errorf("%w: the last admin cannot be demoted", models.ErrInvalidInput)
+		}
+
+		result, err := tx.ExecContext(ctx, `UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2 AND deleted_at IS NULL`,
+			role, id)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("user %w", models.ErrNotFound)
+		}
+
+		return tx.Commit()
+	})
+}
+
+// UpdatePassword replaces a user's password hash and records when it changed
+func (repo *UsersRepository) UpdatePassword(ctx context.Context, id string, password string, changedAt time.Time) error {
+	stmt := `UPDATE users SET password = $1, password_changed_at = $2, updated_at = NOW() WHERE id = $3 AND deleted_at IS NULL`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, password, changedAt, id)
+		return err
+	})
+}
+
+// UpdatePasswordHash replaces a user's password hash with another of the same password
+func (repo *UsersRepository) UpdatePasswordHash(ctx context.Context, id string, password string) error {
+	stmt := `UPDATE users SET password = $1 WHERE id = $2 AND deleted_at IS NULL`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, password, id)
+		return err
+	})
+}
+
+// DeleteProfile deletes a user's account and cleans up their posts according to the policy,
+// returning the IDs of the removed posts
+func (repo *UsersRepository) Delete(ctx context.Context, id string, policy models.DeletionPolicy) ([]string, error) {
+	var postsStmt, userStmt string
+	switch policy {
+	case models.DeletionPolicyDelete:
+		postsStmt = `DELETE FROM posts WHERE author_id = $1 RETURNING id`
+		userStmt = `DELETE FROM users WHERE id = $1`
+	case models.DeletionPolicySoftDelete:
+		postsStmt = `UPDATE posts SET deleted_at = NOW() WHERE author_id = $1 AND deleted_at IS NULL RETURNING id`
+		userStmt = `UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	default:
+		return nil, fmt.Errorf("unknown deletion policy %q", policy)
+	}
+
+	// Replaying the deletion would return no posts, it is only retried when it was rolled back
+	var postIDs []string
+	err := repo.db.Do(ctx, func(ctx context.Context) error {
+		tx, err := repo.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		rows, err := tx.QueryContext(ctx, postsStmt, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		postIDs = []string{}
+		for rows.Next() {
+			var postID string
+			if err := rows.Scan(&postID); err != nil {
+				return err
+			}
+			postIDs = append(postIDs, postID)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, userStmt, id); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return postIDs, nil
 }
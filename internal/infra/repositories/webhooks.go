@@ -0,0 +1,259 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+	"github.com/jdashel/posts-api/internal/infra/database"
+	"github.com/lib/pq"
+)
+
+const webhookColumns = `id, owner_id, url, events, secret, active, created_at, updated_at`
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, response_status, last_error, next_attempt_at, delivered_at, created_at, updated_at`
+
+type WebhooksRepository struct {
+	db *database.DB
+}
+
+// WebhooksRepository constructor
+func NewWebhooksRepository(db *database.DB) *WebhooksRepository {
+	return &WebhooksRepository{db: db}
+}
+
+func scanWebhook(row scanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := row.Scan(&webhook.ID, &webhook.OwnerID, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret,
+		&webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func scanDelivery(row scanner, extra ...any) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload []byte
+	var nextAttemptAt, deliveredAt sql.NullTime
+	dest := []any{&delivery.ID, &delivery.WebhookID, &delivery.Event, &payload, &delivery.Status, &delivery.Attempts,
+		&delivery.ResponseStatus, &delivery.LastError, &nextAttemptAt, &deliveredAt, &delivery.CreatedAt, &delivery.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = payload
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
+
+// Create a new webhook
+func (repo *WebhooksRepository) Create(ctx context.Context, webhook *models.Webhook) (*models.Webhook, error) {
+	stmt := `INSERT INTO webhooks (id, owner_id, url, events, secret, active) VALUES ($1, $2, $3, $4, $5, $6) RETURNING ` + webhookColumns
+	var created *models.Webhook
+	err := repo.db.Do(ctx, func(ctx context.Context) (err error) {
+		row := repo.db.QueryRowContext(ctx, stmt, webhook.ID, webhook.OwnerID, webhook.URL, pq.Array(webhook.Events), webhook.Secret, webhook.Active)
+		created, err = scanWebhook(row)
+		return err
+	})
+	return created, err
+}
+
+// Read a webhook by id for its owner
+func (repo *WebhooksRepository) Read(ctx context.Context, id string, ownerId string) (*models.Webhook, error) {
+	stmt := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1 AND owner_id = $2`
+	var webhook *models.Webhook
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		webhook, err = scanWebhook(repo.db.QueryRowContext(ctx, stmt, id, ownerId))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook %w", models.ErrNotFound)
+	}
+	return webhook, err
+}
+
+// Find the webhooks of an owner
+func (repo *WebhooksRepository) Find(ctx context.Context, ownerId string) ([]*models.Webhook, error) {
+	stmt := `SELECT ` + webhookColumns + ` FROM webhooks WHERE owner_id = $1 ORDER BY created_at ASC`
+	return repo.query(ctx, stmt, ownerId)
+}
+
+// FindSubscribed finds the active webhooks of an owner subscribed to an event type
+func (repo *WebhooksRepository) FindSubscribed(ctx context.Context, event string, ownerId string) ([]*models.Webhook, error) {
+	stmt := `SELECT ` + webhookColumns + ` FROM webhooks WHERE active AND owner_id = $3 AND ($1 = ANY(events) OR $2 = ANY(events))`
+	return repo.query(ctx, stmt, event, models.WebhookAllEvents, ownerId)
+}
+
+func (repo *WebhooksRepository) query(ctx context.Context, stmt string, args ...any) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		webhooks = []*models.Webhook{}
+		for rows.Next() {
+			webhook, err := scanWebhook(rows)
+			if err != nil {
+				return err
+			}
+			webhooks = append(webhooks, webhook)
+		}
+
+		return rows.Err()
+	})
+	return webhooks, err
+}
+
+// Update a webhook's url, events and active flag
+func (repo *WebhooksRepository) Update(ctx context.Context, id string, ownerId string, webhook *models.Webhook) (*models.Webhook, error) {
+	stmt := `UPDATE webhooks SET url = $1, events = $2, active = $3, updated_at = NOW() WHERE id = $4 AND owner_id = $5 RETURNING ` + webhookColumns
+	var updated *models.Webhook
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		updated, err = scanWebhook(repo.db.QueryRowContext(ctx, stmt, webhook.URL, pq.Array(webhook.Events), webhook.Active, id, ownerId))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook %w", models.ErrNotFound)
+	}
+	return updated, err
+}
+
+// Delete a webhook along with its deliveries
+func (repo *WebhooksRepository) Delete(ctx context.Context, id string, ownerId string) error {
+	stmt := `DELETE FROM webhooks WHERE id = $1 AND owner_id = $2`
+	affected, err := affectedRows(ctx, repo.db, stmt, id, ownerId)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("webhook %w", models.ErrNotFound)
+	}
+	return nil
+}
+
+// CreateDelivery records a new delivery
+func (repo *WebhooksRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	stmt := `INSERT INTO webhook_deliveries (id, webhook_id, event, payload, status, next_attempt_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	return repo.db.Do(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, delivery.ID, delivery.WebhookID, delivery.Event, string(delivery.Payload),
+			delivery.Status, delivery.NextAttemptAt)
+		return err
+	})
+}
+
+// ReadDelivery reads a delivery of a webhook
+func (repo *WebhooksRepository) ReadDelivery(ctx context.Context, id string, webhookId string) (*models.WebhookDelivery, error) {
+	stmt := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2`
+	var delivery *models.WebhookDelivery
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) (err error) {
+		delivery, err = scanDelivery(repo.db.QueryRowContext(ctx, stmt, id, webhookId))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("delivery %w", models.ErrNotFound)
+	}
+	return delivery, err
+}
+
+// FindDeliveries retrieves the delivery log of a webhook with pagination, newest first
+func (repo *WebhooksRepository) FindDeliveries(ctx context.Context, webhookId string, pageNumber int, pageSize int) ([]*models.WebhookDelivery, error) {
+	offset := (pageNumber - 1) * pageSize
+
+	stmt := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC OFFSET $2 LIMIT $3`
+	var deliveries []*models.WebhookDelivery
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, webhookId, offset, pageSize)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		deliveries = []*models.WebhookDelivery{}
+		for rows.Next() {
+			delivery, err := scanDelivery(rows)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, delivery)
+		}
+
+		return rows.Err()
+	})
+	return deliveries, err
+}
+
+// UpdateDelivery stores the outcome of a delivery attempt
+func (repo *WebhooksRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	stmt := `UPDATE webhook_deliveries SET status = $1, attempts = $2, response_status = $3, last_error = $4,
+		next_attempt_at = $5, delivered_at = $6, updated_at = NOW() WHERE id = $7`
+	return repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		_, err := repo.db.ExecContext(ctx, stmt, delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.LastError,
+			delivery.NextAttemptAt, delivery.DeliveredAt, delivery.ID)
+		return err
+	})
+}
+
+// ClaimDueDeliveries leases due deliveries so concurrent dispatchers do not send them twice
+func (repo *WebhooksRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	stmt := `WITH due AS (
+		UPDATE webhook_deliveries SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $3 AND next_attempt_at <= $1
+			ORDER BY next_attempt_at ASC LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns + `
+	)
+	SELECT due.*, w.id, w.owner_id, w.url, w.events, w.secret, w.active, w.created_at, w.updated_at
+	FROM due JOIN webhooks w ON w.id = due.webhook_id`
+	// A replayed claim would miss the deliveries leased by the first, until their lease ends
+	var deliveries []*models.WebhookDelivery
+	err := repo.db.Do(ctx, func(ctx context.Context) error {
+		rows, err := repo.db.QueryContext(ctx, stmt, now, leaseUntil, models.DeliveryPending, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		deliveries = []*models.WebhookDelivery{}
+		for rows.Next() {
+			var webhook models.Webhook
+			delivery, err := scanDelivery(rows, &webhook.ID, &webhook.OwnerID, &webhook.URL, pq.Array(&webhook.Events),
+				&webhook.Secret, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt)
+			if err != nil {
+				return err
+			}
+			delivery.Webhook = &webhook
+			deliveries = append(deliveries, delivery)
+		}
+
+		return rows.Err()
+	})
+	return deliveries, err
+}
+
+// RenewDeliveryLease extends a lease, unless it ended and another dispatcher claimed the delivery
+func (repo *WebhooksRepository) RenewDeliveryLease(ctx context.Context, id string, leasedUntil time.Time, leaseUntil time.Time) (bool, error) {
+	stmt := `UPDATE webhook_deliveries SET next_attempt_at = $3 WHERE id = $1 AND status = $4 AND next_attempt_at = $2`
+	var affected int64
+	err := repo.db.DoIdempotent(ctx, func(ctx context.Context) error {
+		result, err := repo.db.ExecContext(ctx, stmt, id, leasedUntil, leaseUntil, models.DeliveryPending)
+		if err != nil {
+			return err
+		}
+		affected, err = result.RowsAffected()
+		return err
+	})
+	return affected > 0, err
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// apiKeyTouchInterval limits how often the last use of a key is written
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyTokenService accepts personal API keys wherever a JWT is expected. Keys are recognized by
+// their prefix, with or without the "Bearer " scheme, and every other call is passed to the
+// wrapped TokenService.
+type APIKeyTokenService struct {
+	interfaces.TokenService
+	keys         interfaces.APIKeysRepository
+	users        interfaces.UsersRepository
+	clockService interfaces.ClockService
+	logger       *slog.Logger
+}
+
+func NewAPIKeyTokenService(tokenService interfaces.TokenService, keys interfaces.APIKeysRepository, users interfaces.UsersRepository,
+	clockService interfaces.ClockService, logger *slog.Logger) *APIKeyTokenService {
+	return &APIKeyTokenService{tokenService, keys, users, clockService, logger.With("component", "api_keys")}
+}
+
+// ParseToken verifies an API key and returns claims shaped like those of a JWT, with the key's
+// scopes and ID, or parses the JWT
+func (s *APIKeyTokenService) ParseToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	if claims, ok := models.TokenClaimsFromContext(ctx, tokenString); ok {
+		return claims, nil
+	}
+
+	apiKey, ok := strings.CutPrefix(strings.TrimPrefix(tokenString, "Bearer "), models.APIKeyPrefix)
+	if !ok {
+		return s.TokenService.ParseToken(ctx, tokenString)
+	}
+
+	ctx, span := tracer.Start(ctx, "APIKeyTokenService.ParseToken")
+	defer span.End()
+
+	id, secret, ok := strings.Cut(apiKey, "_")
+	if !ok {
+		return nil, fmt.Errorf("malformed API key")
+	}
+	key, err := s.keys.Read(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API key")
+	}
+	now := s.clockService.Now()
+	if !key.Usable(now) {
+		return nil, fmt.Errorf("API key is revoked or expired")
+	}
+	if subtle.ConstantTimeCompare([]byte(models.HashAPIKeySecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, fmt.Errorf("invalid API key")
+	}
+
+	user, err := s.users.Read(ctx, key.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Suspended() {
+		return nil, fmt.Errorf("account is suspended")
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.keys.Touch(ctx, key.ID, now); err != nil {
+			s.logger.ErrorContext(ctx, "failed to record last use", "api_key_id", key.ID, "error", err)
+		}
+	}
+
+	scopes := make([]any, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = scope
+	}
+	return jwt.MapClaims{
+		"user_id":    user.ID,
+		"role":       user.Role,
+		"scopes":     scopes,
+		"api_key_id": key.ID,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// bundledBreachedPasswords lists the SHA-1 of the most common breached passwords
+//
+//go:embed data/breached_passwords.txt
+var bundledBreachedPasswords []byte
+
+// BreachedPasswordsService serves ranges of breached password hashes from a local corpus, so
+// screening works offline. The corpus has one upper case SHA-1 per line, optionally followed by
+// ":<count>" as in the Pwned Passwords downloads, and is kept in memory.
+type BreachedPasswordsService struct {
+	ranges map[string][]string
+}
+
+// NewBreachedPasswordsService loads the corpus at path, or the bundled one when path is empty
+func NewBreachedPasswordsService(path string) (*BreachedPasswordsService, error) {
+	var corpus io.Reader = bytes.NewReader(bundledBreachedPasswords)
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		corpus = file
+	}
+
+	ranges := map[string][]string{}
+	scanner := bufio.NewScanner(corpus)
+	for line := 1; scanner.Scan(); line++ {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+		if len(hash) != 40 || strings.Trim(strings.ToUpper(hash), "0123456789ABCDEF") != "" {
+			return nil, fmt.Errorf("line %d is not a SHA-1 hash", line)
+		}
+		hash = strings.ToUpper(hash)
+		ranges[hash[:5]] = append(ranges[hash[:5]], hash[5:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &BreachedPasswordsService{ranges}, nil
+}
+
+// Range returns the hash suffixes of the breached passwords whose hash starts with prefix
+func (s *BreachedPasswordsService) Range(ctx context.Context, prefix string) ([]string, error) {
+	return s.ranges[strings.ToUpper(prefix)], nil
+}
@@ -0,0 +1,15 @@
+package services
+
+import "time"
+
+// ClockService tells the current UTC time
+type ClockService struct{}
+
+func NewClockService() *ClockService {
+	return &ClockService{}
+}
+
+// Now returns the current time in UTC
+func (c *ClockService) Now() time.Time {
+	return time.Now().UTC()
+}
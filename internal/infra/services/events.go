@@ -0,0 +1,33 @@
+package services
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// EventsService fans broadcast events out to the webhook subscribers, and the public ones to the
+// websocket clients, which are anonymous
+type EventsService struct {
+	socket   interfaces.SocketService
+	webhooks interfaces.WebhookDispatcher
+}
+
+func NewEventsService(socket interfaces.SocketService, webhooks interfaces.WebhookDispatcher) *EventsService {
+	return &EventsService{socket, webhooks}
+}
+
+func (events *EventsService) Broadcast(message models.SocketMessage) {
+	if message.Public() {
+		events.socket.Broadcast(message)
+	}
+	events.webhooks.Dispatch(message)
+}
+
+func (events *EventsService) RequestHandler() gin.HandlerFunc {
+	return events.socket.RequestHandler()
+}
+
+func (events *EventsService) Running() bool {
+	return events.socket.Running()
+}
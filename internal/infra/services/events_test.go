@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// recordedEvents records the event types it receives, as a socket and as a dispatcher
+type recordedEvents struct {
+	interfaces.SocketService
+	interfaces.WebhookDispatcher
+	types []string
+}
+
+func (r *recordedEvents) Broadcast(message models.SocketMessage) {
+	r.types = append(r.types, message.Type)
+}
+
+func (r *recordedEvents) Dispatch(message models.SocketMessage) {
+	r.types = append(r.types, message.Type)
+}
+
+func TestEventsOnlyStreamPublicEventsToWebsockets(t *testing.T) {
+	socket, webhooks := &recordedEvents{}, &recordedEvents{}
+	events := NewEventsService(socket, webhooks)
+
+	now := time.Now()
+	hidden := &models.Post{ID: "hidden", AuthorID: "author", HiddenAt: &now}
+	events.Broadcast(models.NewPostCreatedMessage(&models.Post{ID: "post", AuthorID: "author"}, now))
+	events.Broadcast(models.NewPostUpdatedMessage(hidden, now))
+	events.Broadcast(models.NewPostDeletedMessage("post", "author", now))
+	events.Broadcast(models.NewUserUpdatedMessage("author", now))
+	events.Broadcast(models.NewUserDeletedMessage("author", now))
+
+	if len(socket.types) != 2 || socket.types[0] != models.EventPostCreated || socket.types[1] != models.EventPostDeleted {
+		t.Errorf("websockets received %v, want the visible post events only", socket.types)
+	}
+	if len(webhooks.types) != 5 {
+		t.Errorf("webhooks received %v, want every event", webhooks.types)
+	}
+}
@@ -1,6 +1,10 @@
 package services
 
 import (
+	"context"
+	"log/slog"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jdashel/posts-api/internal/domain/models"
 	websockets "github.com/jdashel/posts-api/internal/infra/services/gorilla-socket"
@@ -10,9 +14,10 @@ type GorillaSocketService struct {
 	Hub *websockets.Hub
 }
 
-func NewGorillaSocketService() *GorillaSocketService {
+func NewGorillaSocketService(logger *slog.Logger, clientBuffer int, writeTimeout time.Duration,
+	allowedOrigins []string) *GorillaSocketService {
 	service := &GorillaSocketService{
-		Hub: websockets.NewHub(),
+		Hub: websockets.NewHub(logger.With("component", "websocket"), clientBuffer, writeTimeout, allowedOrigins),
 	}
 
 	go service.Hub.Run()
@@ -27,3 +32,13 @@ func (socket *GorillaSocketService) Broadcast(message models.SocketMessage) {
 func (socket *GorillaSocketService) RequestHandler() gin.HandlerFunc {
 	return socket.Hub.HandleSocket()
 }
+
+// Running tells whether the hub serving the websocket clients is running
+func (socket *GorillaSocketService) Running() bool {
+	return socket.Hub.Running()
+}
+
+// Stop disconnects every client and stops the hub
+func (socket *GorillaSocketService) Stop(ctx context.Context) error {
+	return socket.Hub.Stop(ctx)
+}
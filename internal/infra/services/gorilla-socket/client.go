@@ -1,6 +1,13 @@
 package websockets
 
-import "github.com/gorilla/websocket"
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// closeGoingAway is the close frame sent to clients when the server shuts down
+var closeGoingAway = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
 
 type Client struct {
 	hub      *Hub
@@ -13,19 +20,45 @@ func NewClient(hub *Hub, socket *websocket.Conn) *Client {
 	return &Client{
 		hub:      hub,
 		socket:   socket,
-		outbound: make(chan []byte),
+		outbound: make(chan []byte, hub.clientBuffer),
 	}
 }
 
+// Read discards incoming messages until the connection closes, then unregisters the client
+func (c *Client) Read() {
+	defer func() {
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.stopped:
+		}
+	}()
+
+	for {
+		if _, _, err := c.socket.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// Write sends the broadcast messages to the client, then a close frame once the client is
+// disconnected or the hub stopped
 func (c *Client) Write() {
+	defer c.hub.writers.Done()
+
 	for {
 		select {
 		case message, ok := <-c.outbound:
+			c.socket.SetWriteDeadline(time.Now().Add(c.hub.writeTimeout))
 			if !ok {
-				c.socket.WriteMessage(websocket.CloseMessage, []byte{})
+				c.socket.WriteMessage(websocket.CloseMessage, closeGoingAway)
+				c.socket.Close()
+				return
+			}
+			if err := c.socket.WriteMessage(websocket.TextMessage, message); err != nil {
+				// Closing the socket ends Read, which unregisters the client
+				c.socket.Close()
 				return
 			}
-			c.socket.WriteMessage(websocket.TextMessage, message)
 		}
 	}
 }
@@ -1,68 +1,163 @@
 package websockets
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	// You can customize options here, such as:
-	// ReadBufferSize:  1024,
-	// WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// Add your origin checking logic here
-		return true // Example: Allow all origins for now
-	},
-}
-
 type Hub struct {
+	upgrader   websocket.Upgrader
 	clients    []*Client
 	register   chan *Client
 	unregister chan *Client
 	mutex      *sync.Mutex
+	logger     *slog.Logger
+	stop       chan struct{}
+	stopped    chan struct{}
+	stopOnce   sync.Once
+	writers    sync.WaitGroup
+	closed     bool // Set once stopped, guarded by mutex
+
+	// clientBuffer is how many messages may wait for a slow client before new ones are dropped
+	clientBuffer int
+	// writeTimeout is how long writing a message to a client may take before it is disconnected
+	writeTimeout time.Duration
+	// allowedOrigins are the origins browsers may open websockets from, any when empty
+	allowedOrigins []string
+
+	running    atomic.Bool
+	broadcasts atomic.Uint64
+	dropped    atomic.Uint64
 }
 
-func NewHub() *Hub {
-	return &Hub{
+// NewHub creates a hub accepting websockets opened from allowedOrigins, or from anywhere when
+// there is none. Requests without an Origin header do not come from browsers and are accepted.
+func NewHub(logger *slog.Logger, clientBuffer int, writeTimeout time.Duration, allowedOrigins []string) *Hub {
+	hub := &Hub{
 		clients:    make([]*Client, 0),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		mutex:      &sync.Mutex{},
+		logger:     logger,
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+
+		clientBuffer:   clientBuffer,
+		writeTimeout:   writeTimeout,
+		allowedOrigins: allowedOrigins,
+	}
+	hub.upgrader = websocket.Upgrader{CheckOrigin: hub.checkOrigin}
+
+	return hub
+}
+
+// checkOrigin accepts the websockets opened from the allowed origins
+func (hub *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(hub.allowedOrigins) == 0 || origin == "" {
+		return true
 	}
+	return slices.ContainsFunc(hub.allowedOrigins, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	})
 }
 
 func (hub *Hub) HandleSocket() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		socket, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		socket, err := hub.upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
-			log.Println(err)
+			hub.logger.WarnContext(c.Request.Context(), "failed to upgrade connection", "error", err)
 			c.JSON(http.StatusBadRequest, gin.H{"Could not open connection": err.Error()})
 			return
 		}
 
 		client := NewClient(hub, socket)
-		hub.register <- client
+		if !hub.addWriter() {
+			hub.goingAway(socket)
+			return
+		}
+		select {
+		case hub.register <- client:
+		case <-hub.stopped:
+			hub.writers.Done()
+			hub.goingAway(socket)
+			return
+		}
 
 		go client.Write()
+		go client.Read()
 	}
 }
 
+// addWriter counts the writer of a new client before the hub knows it, so Stop waits for its close
+// frame. It refuses once the hub stopped, as Stop may already be waiting for the writers.
+func (hub *Hub) addWriter() bool {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if hub.closed {
+		return false
+	}
+	hub.writers.Add(1)
+	return true
+}
+
+// goingAway closes the socket of a client that connected while the hub stopped
+func (hub *Hub) goingAway(socket *websocket.Conn) {
+	socket.WriteControl(websocket.CloseMessage, closeGoingAway, time.Now().Add(hub.writeTimeout))
+	socket.Close()
+}
+
+// Run connects and disconnects clients until the hub is stopped
 func (hub *Hub) Run() {
+	hub.running.Store(true)
+	defer hub.running.Store(false)
+	defer close(hub.stopped)
+
 	for {
 		select {
 		case client := <-hub.register:
 			hub.onConnect(client)
 		case client := <-hub.unregister:
 			hub.onDisconnect(client)
+		case <-hub.stop:
+			hub.onStop()
+			return
 		}
 	}
 }
 
+// Stop disconnects every client with a close frame telling them the server is going away, and
+// stops the hub. It returns once the close frames are written, or with the error of ctx when it
+// is done first.
+func (hub *Hub) Stop(ctx context.Context) error {
+	hub.stopOnce.Do(func() { close(hub.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		<-hub.stopped
+		hub.writers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (hub *Hub) onConnect(client *Client) {
 	hub.mutex.Lock()
 	defer hub.mutex.Unlock()
@@ -70,7 +165,7 @@ func (hub *Hub) onConnect(client *Client) {
 	client.id = client.socket.RemoteAddr().String()
 	hub.clients = append(hub.clients, client)
 
-	log.Println("Client connected")
+	hub.logger.Debug("client connected", "client", client.id, "clients", len(hub.clients))
 }
 
 func (hub *Hub) onDisconnect(client *Client) {
@@ -79,21 +174,70 @@ func (hub *Hub) onDisconnect(client *Client) {
 	defer hub.mutex.Unlock()
 
 	for i, other := range hub.clients {
-		if other.id == client.id {
+		if other == client {
 			hub.clients = append(hub.clients[:i], hub.clients[i+1:]...)
+			// No broadcast can reach the client anymore, ending its writer
+			close(client.outbound)
 			break
 		}
 	}
 
-	log.Println("Client disconnected")
+	hub.logger.Debug("client disconnected", "client", client.id, "clients", len(hub.clients))
+}
+
+// onStop ends the writer of every client, which sends the close frame and closes the socket
+func (hub *Hub) onStop() {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	for _, client := range hub.clients {
+		close(client.outbound)
+	}
+	hub.logger.Info("hub stopped", "clients", len(hub.clients))
+	hub.clients = nil
+	hub.closed = true
 }
 
+// Broadcast sends a message to every client but ignore, it is dropped for the clients too slow
+// to keep up rather than holding up the others
 func (hub *Hub) Broadcast(message any, ignore *Client) {
 	data, _ := json.Marshal(message)
 
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.broadcasts.Add(1)
 	for _, client := range hub.clients {
-		if client != ignore {
-			client.outbound <- data
+		if client == ignore {
+			continue
+		}
+		select {
+		case client.outbound <- data:
+		default:
+			hub.dropped.Add(1)
 		}
 	}
 }
+
+// Running tells whether the hub is accepting and disconnecting clients
+func (hub *Hub) Running() bool {
+	return hub.running.Load()
+}
+
+// Clients returns the number of connected clients
+func (hub *Hub) Clients() int {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	return len(hub.clients)
+}
+
+// Broadcasts returns the number of messages broadcast so far
+func (hub *Hub) Broadcasts() uint64 {
+	return hub.broadcasts.Load()
+}
+
+// Dropped returns the number of messages dropped for slow clients so far
+func (hub *Hub) Dropped() uint64 {
+	return hub.dropped.Load()
+}
@@ -1,27 +1,131 @@
 package services
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashManager handles password hashing
-type HashService struct{}
+// Password hashing algorithms
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+// bcryptPrefix tags bcrypt hashes of peppered passwords, telling them apart from the bare
+// bcrypt hashes stored before, which only covered the first 72 bytes of the password
+const bcryptPrefix = "$bcrypt-sha256"
+
+// Argon2Params are the cost parameters of argon2id hashes
+type Argon2Params struct {
+	// Memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// HashService handles password hashing. Passwords are first run through HMAC-SHA256 keyed with
+// the pepper, so every byte of long passwords counts and stolen hashes are useless without the
+// pepper, then hashed with the configured algorithm. Hashes carry their algorithm and parameters
+// in the PHC string format, such as "$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>".
+type HashService struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+	pepper     []byte
+}
 
-func NewHashService() *HashService {
-	return &HashService{}
+func NewHashService(algorithm string, argon2 Argon2Params, bcryptCost int, pepper string) *HashService {
+	return &HashService{algorithm: algorithm, argon2: argon2, bcryptCost: bcryptCost, pepper: []byte(pepper)}
 }
 
-// HashPassword hashes a password using bcrypt
+// HashPassword hashes a password with the configured algorithm
 func (hm *HashService) HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
+	if hm.algorithm == HashBcrypt {
+		hashedPassword, err := bcrypt.GenerateFromPassword(hm.peppered(password), hm.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return bcryptPrefix + string(hashedPassword), nil
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	return string(hashedPassword), nil
+	key := argon2.IDKey(hm.peppered(password), salt, hm.argon2.Iterations, hm.argon2.Memory, hm.argon2.Parallelism, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, hm.argon2.Memory, hm.argon2.Iterations,
+		hm.argon2.Parallelism, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
 }
 
-// ComparePassword compares a plain password with a hashed password
+// ComparePassword compares a plain password with a hashed password of any supported format
 func (hm *HashService) ComparePassword(password string, hashedPassword string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	return err == nil
+	if bcryptHash, ok := strings.CutPrefix(hashedPassword, bcryptPrefix); ok {
+		return bcrypt.CompareHashAndPassword([]byte(bcryptHash), hm.peppered(password)) == nil
+	}
+	if strings.HasPrefix(hashedPassword, "$2") {
+		// Bare bcrypt hash of the password itself
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) == nil
+	}
+
+	params, salt, key, err := parseArgon2id(hashedPassword)
+	if err != nil {
+		return false
+	}
+	other := argon2.IDKey(hm.peppered(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// NeedsRehash tells whether a hash was made with another algorithm or other parameters than
+// the configured ones, it should then be replaced the next time the password is known
+func (hm *HashService) NeedsRehash(hashedPassword string) bool {
+	if bcryptHash, ok := strings.CutPrefix(hashedPassword, bcryptPrefix); ok {
+		cost, err := bcrypt.Cost([]byte(bcryptHash))
+		return hm.algorithm != HashBcrypt || err != nil || cost != hm.bcryptCost
+	}
+
+	params, _, _, err := parseArgon2id(hashedPassword)
+	return hm.algorithm != HashArgon2id || err != nil || params != hm.argon2
+}
+
+// peppered returns the HMAC of the password keyed with the pepper, base64 encoded since bcrypt
+// stops at NUL bytes
+func (hm *HashService) peppered(password string) []byte {
+	mac := hmac.New(sha256.New, hm.pepper)
+	mac.Write([]byte(password))
+	return []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// parseArgon2id parses a "$argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<hash>" hash
+func parseArgon2id(hashedPassword string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return params, nil, nil, fmt.Errorf("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2 hash")
+	}
+	return params, salt, key, nil
 }
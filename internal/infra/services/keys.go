@@ -0,0 +1,429 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// Key algorithms
+const (
+	KeyAlgorithmEdDSA = "EdDSA"
+	KeyAlgorithmRS256 = "RS256"
+)
+
+const (
+	keyReloadInterval = time.Minute
+	// A new key only signs once every instance had time to reload it and accept its tokens
+	keyActivationDelay = 2 * keyReloadInterval
+	// The instance rotating the keys holds the lock file, left behind by a crash it expires
+	keyLockFile = "rotate.lock"
+	keyLockTTL  = keyReloadInterval
+	rsaKeyBits  = 2048
+)
+
+// signingKey is a key of the ring, private is nil for verification-only keys
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   any
+	public    any
+	createdAt time.Time
+}
+
+// KeyRing holds the token signing keys, loaded from a directory shared by every instance.
+// Each "<kid>.pem" file holds a PKCS #8 private key, RSA or Ed25519, and each "<kid>.pub.pem"
+// a PKIX public key only accepted for verification. The newest private key, by file
+// modification time, signs new tokens once it is older than the activation delay, while all
+// the others still verify the tokens they signed. Rotation generates a new key every rotation
+// interval and deletes the keys retired for longer than retention, which must exceed the
+// lifetime of the longest token. One instance at a time rotates, holding a lock file.
+type KeyRing struct {
+	dir       string
+	algorithm string
+	rotation  time.Duration
+	retention time.Duration
+	logger    *slog.Logger
+
+	mu      sync.RWMutex
+	keys    map[string]*signingKey
+	current *signingKey
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewKeyRing loads the keys of dir, generating a first one when there is none,
+// and starts rotating them unless rotation is zero
+func NewKeyRing(dir string, algorithm string, rotation time.Duration, retention time.Duration, logger *slog.Logger) (*KeyRing, error) {
+	if algorithm != KeyAlgorithmEdDSA && algorithm != KeyAlgorithmRS256 {
+		return nil, fmt.Errorf("unsupported key algorithm %q", algorithm)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	ring := &KeyRing{dir: dir, algorithm: algorithm, rotation: rotation, retention: retention, logger: logger.With("component", "keys"),
+		stop: make(chan struct{}), stopped: make(chan struct{})}
+	if err := ring.load(); err != nil {
+		return nil, err
+	}
+
+	// Instances starting together wait for the one generating the first key
+	deadline := time.Now().Add(keyLockTTL)
+	for ring.signing() == nil {
+		if err := ring.rotate(); err != nil {
+			return nil, err
+		}
+		if ring.signing() != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("no signing key was generated, %s is held", keyLockFile)
+		}
+		time.Sleep(time.Second)
+		if err := ring.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	go ring.Run()
+
+	return ring, nil
+}
+
+// Run reloads the keys periodically, picking up those added by other instances, and rotates them
+// until stopped
+func (r *KeyRing) Run() {
+	defer close(r.stopped)
+
+	ticker := time.NewTicker(keyReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+
+		if err := r.load(); err != nil {
+			r.logger.Error("failed to reload keys", "error", err)
+			continue
+		}
+		if r.rotation > 0 {
+			if err := r.rotate(); err != nil {
+				r.logger.Error("failed to rotate keys", "error", err)
+			}
+		}
+	}
+}
+
+// Stop stops reloading and rotating the keys, a rotation in progress is finished first. It
+// returns with the error of ctx when ctx is done first.
+func (r *KeyRing) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	select {
+	case <-r.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// JWKS returns the public keys of the ring
+func (r *KeyRing) JWKS() models.JSONWebKeySet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	for _, key := range r.keys {
+		jwk := models.JSONWebKey{Use: "sig", Kid: key.kid, Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set
+}
+
+// signing returns the key new tokens are signed with
+func (r *KeyRing) signing() *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// verification returns the key identified by kid
+func (r *KeyRing) verification(kid string) (*signingKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[kid]
+	return key, ok
+}
+
+// rotate generates a new key once the newest one is due, or when there is none, and deletes the
+// keys retired long enough. It does nothing while another instance holds the rotation lock.
+func (r *KeyRing) rotate() error {
+	locked, err := r.lock()
+	if err != nil || !locked {
+		return err
+	}
+	defer r.unlock()
+
+	// Another instance may have rotated since the last reload
+	if err := r.load(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	keys := r.privateKeys()
+	if len(keys) == 0 || r.rotation > 0 && now.Sub(keys[len(keys)-1].createdAt) >= r.rotation {
+		if err := r.generate(); err != nil {
+			return err
+		}
+		keys = r.privateKeys()
+	}
+
+	// A key is retired when a newer private key took over signing
+	if r.rotation > 0 {
+		for i := 0; i < len(keys)-1; i++ {
+			if retiredAt := keys[i+1].createdAt.Add(keyActivationDelay); now.Sub(retiredAt) > r.retention {
+				if err := os.Remove(filepath.Join(r.dir, keys[i].kid+".pem")); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				r.logger.Info("deleted key", "kid", keys[i].kid, "retired_at", retiredAt)
+			}
+		}
+	}
+
+	return r.load()
+}
+
+// privateKeys returns the private keys of the ring, oldest first
+func (r *KeyRing) privateKeys() []*signingKey {
+	r.mu.RLock()
+	keys := make([]*signingKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.private != nil {
+			keys = append(keys, key)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].createdAt.Before(keys[j].createdAt) })
+
+	return keys
+}
+
+// lock takes the rotation lock, telling whether it got it. A lock older than its TTL was left
+// behind by an instance that stopped while rotating and is taken over.
+func (r *KeyRing) lock() (bool, error) {
+	path := filepath.Join(r.dir, keyLockFile)
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			return true, file.Close()
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return false, err
+		}
+
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // Released meanwhile
+		}
+		if err != nil {
+			return false, err
+		}
+		if time.Since(info.ModTime()) < keyLockTTL {
+			return false, nil
+		}
+		r.logger.Warn("taking over stale rotation lock", "locked_at", info.ModTime())
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (r *KeyRing) unlock() {
+	if err := os.Remove(filepath.Join(r.dir, keyLockFile)); err != nil {
+		r.logger.Error("failed to release rotation lock", "error", err)
+	}
+}
+
+// generate creates a new private key in the directory, it signs once older than the activation delay
+func (r *KeyRing) generate() error {
+	var private any
+	var err error
+	switch r.algorithm {
+	case KeyAlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	kid := time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := writeFileAtomic(filepath.Join(r.dir, kid+".pem"), data); err != nil {
+		return err
+	}
+	r.logger.Info("generated key", "algorithm", r.algorithm, "kid", kid)
+
+	return r.load()
+}
+
+// writeFileAtomic writes data to a temporary file renamed to path, so other instances never load
+// a partial key. The temporary file is only readable by the owner and has no .pem suffix.
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // Fails harmlessly once renamed
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// load reads every key of the directory
+func (r *KeyRing) load() error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*signingKey{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		key, err := r.loadFile(entry)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", entry.Name(), err)
+		}
+		keys[key.kid] = key
+	}
+
+	// The newest active key signs, or the oldest key while none is active yet, such as the first one
+	var current, pending *signingKey
+	activeBefore := time.Now().Add(-keyActivationDelay)
+	for _, key := range keys {
+		switch {
+		case key.private == nil:
+		case !key.createdAt.After(activeBefore):
+			if current == nil || key.createdAt.After(current.createdAt) {
+				current = key
+			}
+		case pending == nil || key.createdAt.Before(pending.createdAt):
+			pending = key
+		}
+	}
+	if current == nil {
+		current = pending
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.current = current
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *KeyRing) loadFile(entry os.DirEntry) (*signingKey, error) {
+	info, err := entry.Info()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(r.dir, entry.Name()))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data")
+	}
+
+	key := &signingKey{createdAt: info.ModTime()}
+	if kid, ok := strings.CutSuffix(entry.Name(), ".pub.pem"); ok {
+		key.kid = kid
+		if key.public, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	} else {
+		key.kid = strings.TrimSuffix(entry.Name(), ".pem")
+		if key.private, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	}
+
+	switch private := key.private.(type) {
+	case *rsa.PrivateKey:
+		key.public = &private.PublicKey
+	case ed25519.PrivateKey:
+		key.public = private.Public()
+	}
+	switch key.public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.public)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// FileMailerService is a development mailer that writes every email to a file
+// in a directory, or to the debug log when no directory is configured
+type FileMailerService struct {
+	from   string
+	dir    string
+	logger *slog.Logger
+}
+
+func NewFileMailerService(from string, dir string, logger *slog.Logger) *FileMailerService {
+	return &FileMailerService{from: from, dir: dir, logger: logger.With("component", "mailer")}
+}
+
+// Send writes the email as an .eml file
+func (m *FileMailerService) Send(ctx context.Context, mail models.Mail) error {
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		m.from, mail.To, mail.Subject, time.Now().Format(time.RFC1123Z), mail.Body)
+
+	if m.dir == "" {
+		// Emails carry links with secret tokens, they are only logged when debugging
+		m.logger.DebugContext(ctx, "email", "to", mail.To, "subject", mail.Subject, "body", mail.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), filepath.Base(mail.To))
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(message), 0o600)
+}
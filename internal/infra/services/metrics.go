@@ -0,0 +1,111 @@
+package services
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	websockets "github.com/jdashel/posts-api/internal/infra/services/gorilla-socket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes the application metrics
+const metricsNamespace = "posts_api"
+
+// MetricsService collects metrics in a Prometheus registry, along with the Go runtime and process ones
+type MetricsService struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	signups         prometheus.Counter
+	postsCreated    prometheus.Counter
+}
+
+func NewMetricsService() *MetricsService {
+	m := &MetricsService{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time spent handling HTTP requests, by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		signups: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "signups_total",
+			Help:      "Accounts created by signup.",
+		}),
+		postsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "posts_created_total",
+			Help:      "Posts created.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.signups,
+		m.postsCreated,
+	)
+
+	return m
+}
+
+// RegisterDB exposes the connection pool statistics of db
+func (m *MetricsService) RegisterDB(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// RegisterHub exposes the connected clients and the broadcasts of the websocket hub
+func (m *MetricsService) RegisterHub(hub *websockets.Hub) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "websocket_clients",
+			Help:      "Websocket clients connected.",
+		}, func() float64 { return float64(hub.Clients()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "websocket_broadcasts_total",
+			Help:      "Messages broadcast to the websocket clients.",
+		}, func() float64 { return float64(hub.Broadcasts()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "websocket_dropped_messages_total",
+			Help:      "Messages dropped for websocket clients too slow to keep up.",
+		}, func() float64 { return float64(hub.Dropped()) }),
+	)
+}
+
+// ObserveRequest records a handled HTTP request
+func (m *MetricsService) ObserveRequest(method string, route string, status int, duration time.Duration) {
+	labels := prometheus.Labels{"method": method, "route": route, "status": strconv.Itoa(status)}
+	m.requests.With(labels).Inc()
+	m.requestDuration.With(labels).Observe(duration.Seconds())
+}
+
+// UserSignedUp counts a new account
+func (m *MetricsService) UserSignedUp() {
+	m.signups.Inc()
+}
+
+// PostCreated counts a new post
+func (m *MetricsService) PostCreated() {
+	m.postsCreated.Inc()
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *MetricsService) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
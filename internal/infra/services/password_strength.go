@@ -0,0 +1,253 @@
+package services
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// commonWords are words and names people build passwords from, matched after undoing
+// substitutions such as "p@ssw0rd"
+var commonWords = []string{
+	"password", "pass", "passwd", "secret", "letmein", "welcome", "hello", "login", "admin", "root", "user", "guest",
+	"test", "default", "changeme", "access", "master", "trustno", "whatever", "iloveyou", "love", "lovely", "forever",
+	"freedom", "qwerty", "dragon", "monkey", "shadow", "sunshine", "princess", "superman", "batman", "starwars",
+	"pokemon", "football", "baseball", "soccer", "hockey", "tennis", "golf", "computer", "internet", "summer", "winter",
+	"spring", "autumn", "january", "february", "march", "april", "may", "june", "july", "august", "september",
+	"october", "november", "december", "monday", "friday", "sunday", "michael", "jennifer", "jessica", "ashley",
+	"daniel", "david", "john", "james", "robert", "maria", "thomas", "charlie", "jordan", "hunter", "killer",
+	"tiger", "lion", "eagle", "flower", "angel", "purple", "orange", "yellow", "silver", "golden", "diamond",
+	"cookie", "cheese", "pepper", "ginger", "banana", "chocolate", "money", "blessed", "jesus", "family", "baby",
+	"dog", "cat", "puppy", "kitty", "matrix", "ninja", "mustang", "ferrari", "chelsea", "liverpool", "arsenal",
+	"barcelona", "london", "paris", "berlin", "posts",
+}
+
+// keyboardRows are the rows of a qwerty keyboard, walked along in passwords such as "asdfgh"
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// leetSubstitutions are the characters commonly standing in for letters
+var leetSubstitutions = map[rune]rune{'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '@': 'a', '$': 's'}
+
+// Feedback given for each weakness found in a password
+const (
+	feedbackUserInput = "avoid using your email address or name"
+	feedbackWord      = "avoid common words and names, even with symbols in place of letters"
+	feedbackYear      = "avoid years and dates"
+	feedbackRepeat    = "avoid repeated characters like \"aaa\""
+	feedbackSequence  = "avoid sequences like \"abc\" or \"321\""
+	feedbackKeyboard  = "avoid keyboard patterns like \"qwerty\""
+	feedbackLonger    = "add another word or two, uncommon words are better than symbols"
+)
+
+// PasswordStrengthService estimates the strength of passwords from the number of guesses an attacker
+// trying common patterns first would need, in the spirit of zxcvbn. The password is split into
+// patterns, each worth the bits needed to guess it, and characters outside any pattern are worth
+// those of a random character of the same classes.
+type PasswordStrengthService struct{}
+
+func NewPasswordStrengthService() *PasswordStrengthService {
+	return &PasswordStrengthService{}
+}
+
+// patternMatch is a pattern found at the start of the rest of a password
+type patternMatch struct {
+	length   int
+	bits     float64
+	feedback string
+}
+
+// Estimate scores a password by the bits of guessing it takes: below 20, 30, 40 and 50 bits
+// it scores 0 to 3, and 4 above
+func (s *PasswordStrengthService) Estimate(password string, userInputs ...string) *models.PasswordStrength {
+	chars := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	unleet := make([]rune, len(lower))
+	for i, char := range lower {
+		if substitute, ok := leetSubstitutions[char]; ok {
+			char = substitute
+		}
+		unleet[i] = char
+	}
+	charBits := math.Log2(float64(characterPool(password)))
+	inputs := userInputTokens(userInputs)
+
+	bits := 0.0
+	feedback := []string{}
+	for i := 0; i < len(chars); {
+		best := patternMatch{length: 1, bits: charBits}
+		for _, match := range []patternMatch{
+			matchUserInput(lower[i:], inputs),
+			matchWord(chars[i:], lower[i:], unleet[i:]),
+			matchYear(lower[i:]),
+			matchRepeat(lower[i:], charBits),
+			matchSequence(lower[i:], charBits),
+			matchKeyboard(lower[i:]),
+		} {
+			if match.length > best.length {
+				best = match
+			}
+		}
+
+		bits += best.bits
+		if best.feedback != "" && !slices.Contains(feedback, best.feedback) {
+			feedback = append(feedback, best.feedback)
+		}
+		i += best.length
+	}
+
+	score := max(0, min(4, int(bits/10)-1))
+	if score < 3 {
+		feedback = append(feedback, feedbackLonger)
+	}
+	return &models.PasswordStrength{Score: score, Feedback: feedback}
+}
+
+// characterPool returns the number of characters in the classes the password uses
+func characterPool(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, char := range password {
+		switch {
+		case char >= 'a' && char <= 'z':
+			lower = true
+		case char >= 'A' && char <= 'Z':
+			upper = true
+		case char >= '0' && char <= '9':
+			digit = true
+		case char <= unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	return max(pool, 1)
+}
+
+// userInputTokens splits user inputs such as emails into the lower case tokens worth matching
+func userInputTokens(userInputs []string) []string {
+	tokens := []string{}
+	for _, input := range userInputs {
+		for _, token := range strings.FieldsFunc(strings.ToLower(input), func(char rune) bool {
+			return !unicode.IsLetter(char) && !unicode.IsDigit(char)
+		}) {
+			if len([]rune(token)) >= 3 {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func matchUserInput(lower []rune, inputs []string) patternMatch {
+	best := patternMatch{}
+	for _, input := range inputs {
+		if length := len([]rune(input)); length > best.length && hasRunePrefix(lower, input) {
+			best = patternMatch{length: length, bits: 1, feedback: feedbackUserInput}
+		}
+	}
+	return best
+}
+
+func matchWord(chars []rune, lower []rune, unleet []rune) patternMatch {
+	best := patternMatch{}
+	for _, word := range commonWords {
+		length := len([]rune(word))
+		if length <= best.length || length < 3 || !hasRunePrefix(unleet, word) {
+			continue
+		}
+
+		bits := math.Log2(float64(len(commonWords)))
+		if slices.ContainsFunc(chars[:length], unicode.IsUpper) {
+			bits++
+		}
+		if !hasRunePrefix(lower, word) {
+			bits++
+		}
+		best = patternMatch{length: length, bits: bits, feedback: feedbackWord}
+	}
+	return best
+}
+
+func matchYear(lower []rune) patternMatch {
+	if len(lower) < 4 {
+		return patternMatch{}
+	}
+	year := string(lower[:4])
+	if strings.Trim(year, "0123456789") != "" || (!strings.HasPrefix(year, "19") && !strings.HasPrefix(year, "20")) {
+		return patternMatch{}
+	}
+	return patternMatch{length: 4, bits: math.Log2(200), feedback: feedbackYear}
+}
+
+func matchRepeat(lower []rune, charBits float64) patternMatch {
+	length := 1
+	for length < len(lower) && lower[length] == lower[0] {
+		length++
+	}
+	if length < 3 {
+		return patternMatch{}
+	}
+	return patternMatch{length: length, bits: charBits + math.Log2(float64(length)), feedback: feedbackRepeat}
+}
+
+func matchSequence(lower []rune, charBits float64) patternMatch {
+	if len(lower) < 3 {
+		return patternMatch{}
+	}
+	step := lower[1] - lower[0]
+	if step != 1 && step != -1 {
+		return patternMatch{}
+	}
+	length := 2
+	for length < len(lower) && lower[length]-lower[length-1] == step {
+		length++
+	}
+	if length < 3 {
+		return patternMatch{}
+	}
+	return patternMatch{length: length, bits: charBits + math.Log2(float64(length)) + 1, feedback: feedbackSequence}
+}
+
+func matchKeyboard(lower []rune) patternMatch {
+	best := patternMatch{}
+	for _, row := range keyboardRows {
+		for _, walk := range []string{row, reverse(row)} {
+			for start := range walk {
+				length := 0
+				for _, char := range walk[start:] {
+					if length >= len(lower) || lower[length] != char {
+						break
+					}
+					length++
+				}
+				if length >= 4 && length > best.length {
+					best = patternMatch{length: length, bits: math.Log2(float64(len(walk))) + math.Log2(float64(length)) + 1,
+						feedback: feedbackKeyboard}
+				}
+			}
+		}
+	}
+	return best
+}
+
+func hasRunePrefix(chars []rune, prefix string) bool {
+	return strings.HasPrefix(string(chars), prefix)
+}
+
+func reverse(value string) string {
+	chars := []rune(value)
+	slices.Reverse(chars)
+	return string(chars)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+const rateLimitSweepInterval = time.Minute
+
+type rateLimitEntry struct {
+	bucket models.TokenBucket
+	fullAt time.Time
+}
+
+// MemoryRateLimitStore keeps token buckets in memory, limits are per instance
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: map[string]*rateLimitEntry{}}
+}
+
+// Take takes a token from the bucket of key
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &rateLimitEntry{}
+		s.entries[key] = entry
+	}
+	result := entry.bucket.Take(limit, now)
+	entry.fullAt = now.Add(result.Reset)
+
+	return result, nil
+}
+
+// sweep forgets the buckets that refilled, they are equivalent to new ones
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, entry := range s.entries {
+		if !now.Before(entry.fullAt) {
+			delete(s.entries, key)
+		}
+	}
+}
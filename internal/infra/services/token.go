@@ -1,34 +1,161 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
 )
 
-// TokenService handles token generation and validation
+// sessionTouchInterval limits how often the last use of a session is written
+const sessionTouchInterval = time.Minute
+
+// TokenService handles token generation and validation, signing with the current key of the ring
 type TokenService struct {
-	secretKey string
+	keys         *KeyRing
+	sessions     interfaces.SessionsRepository
+	uuidService  interfaces.UUIDService
+	clockService interfaces.ClockService
+	logger       *slog.Logger
 }
 
 // NewTokenService creates a new TokenService instance
-func NewTokenService(secretKey string) *TokenService {
-	return &TokenService{secretKey: secretKey}
+func NewTokenService(keys *KeyRing, sessions interfaces.SessionsRepository, uuidService interfaces.UUIDService,
+	clockService interfaces.ClockService, logger *slog.Logger) *TokenService {
+	return &TokenService{keys: keys, sessions: sessions, uuidService: uuidService, clockService: clockService,
+		logger: logger.With("component", "sessions")}
 }
 
-// GenerateToken generates a new authentication token for a user ID
-func (tm *TokenService) GenerateToken(userID string) (string, error) {
-	// Create JWT claims with user ID and expiration time
+// GenerateToken starts a session for a user, described by the request in the context,
+// and generates a new authentication token for it
+func (tm *TokenService) GenerateToken(ctx context.Context, user *models.User, scopes []string) (string, error) {
+	if scopes == nil {
+		scopes = models.Scopes
+	}
+
+	id, err := tm.uuidService.GenerateID(ctx)
+	if err != nil {
+		return "", err
+	}
+	now := tm.clockService.Now()
+	info := models.RequestInfoFromContext(ctx)
+	session := &models.Session{
+		ID:         id,
+		UserID:     user.ID,
+		UserAgent:  info.UserAgent,
+		IP:         info.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(models.SessionTTL),
+	}
+	if err := tm.sessions.Create(ctx, session); err != nil {
+		return "", err
+	}
+
+	// Create JWT claims with user ID, role, scopes, session and expiration time
 	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // Expire in 24 hours
+		"user_id": user.ID,
+		"role":    user.Role,
+		"scopes":  scopes,
+		"sid":     session.ID,
+		"iat":     now.Unix(),
+		"exp":     session.ExpiresAt.Unix(),
 	}
 
-	// Generate the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(tm.secretKey))
+	return tm.sign(claims)
+}
+
+// ParseToken parses a token and returns its claims
+func (tm *TokenService) ParseToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	ctx, span := tracer.Start(ctx, "TokenService.ParseToken")
+	defer span.End()
+
+	claims, err := tm.parse(strings.TrimPrefix(tokenString, "Bearer "))
+	if err != nil {
+		return nil, err
+	}
+
+	// Action tokens only serve their own purpose
+	if _, ok := claims["purpose"]; ok {
+		return nil, fmt.Errorf("not an authentication token")
+	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid user ID in token")
+	}
+
+	// Logging out revokes the session of the token, the session and its user are read at once
+	sessionID, _ := claims["sid"].(string)
+	session, user, err := tm.sessions.ReadWithUser(ctx, sessionID)
+	if err != nil || session.UserID != userID {
+		return nil, fmt.Errorf("token has no session")
+	}
+
+	if user.Suspended() {
+		return nil, fmt.Errorf("account is suspended")
+	}
+	// Tokens issued before the last password change are no longer valid
+	issuedAt, _ := claims["iat"].(float64)
+	if user.PasswordChangedAt != nil && int64(issuedAt) < user.PasswordChangedAt.Unix() {
+		return nil, fmt.Errorf("token has been revoked")
+	}
+	claims["role"] = user.Role // Role changes apply to tokens already issued
+
+	now := tm.clockService.Now()
+	if !session.Active(now) {
+		return nil, fmt.Errorf("session has been revoked")
+	}
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := tm.sessions.Touch(ctx, session.ID, now); err != nil {
+			tm.logger.ErrorContext(ctx, "failed to record last use", "session_id", session.ID, "error", err)
+		}
+	}
+
+	return claims, nil
+}
+
+// GenerateActionToken generates a short-lived token only valid for the given purpose,
+// such as an email verification link
+func (tm *TokenService) GenerateActionToken(purpose string, claims map[string]any, ttl time.Duration) (string, error) {
+	now := tm.clockService.Now()
+	tokenClaims := jwt.MapClaims{
+		"purpose": purpose,
+		"iat":     now.Unix(),
+		"exp":     now.Add(ttl).Unix(),
+	}
+	for key, value := range claims {
+		tokenClaims[key] = value
+	}
+
+	return tm.sign(tokenClaims)
+}
+
+// ParseActionToken parses a token generated for the given purpose and returns its claims
+func (tm *TokenService) ParseActionToken(purpose string, tokenString string) (jwt.MapClaims, error) {
+	claims, err := tm.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims["purpose"] != purpose {
+		return nil, fmt.Errorf("token is not valid for %s", purpose)
+	}
+
+	return claims, nil
+}
+
+func (tm *TokenService) sign(claims jwt.MapClaims) (string, error) {
+	// Generate the token, the kid header tells verifiers which key signed it
+	key := tm.keys.signing()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	tokenString, err := token.SignedString(key.private)
 	if err != nil {
 		return "", err
 	}
@@ -36,13 +163,17 @@ func (tm *TokenService) GenerateToken(userID string) (string, error) {
 	return tokenString, nil
 }
 
-// ParseToken parses a token and returns its claims
-func (tm *TokenService) ParseToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(strings.TrimPrefix(tokenString, "Bearer "), func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+func (tm *TokenService) parse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := tm.keys.verification(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(tm.secretKey), nil
+		return key.public, nil
 	})
 
 	if err != nil {
@@ -53,7 +184,6 @@ func (tm *TokenService) ParseToken(tokenString string) (jwt.MapClaims, error) {
 	if !ok {
 		return nil, fmt.Errorf("invalid claims type: %v", token.Claims)
 	}
-	fmt.Println(claims)
 
 	return claims, nil
 }
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod     = 30 // Seconds
+	totpDigits     = 6
+	totpSecretSize = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPService implements RFC 6238 time-based one-time passwords with the defaults every
+// authenticator app supports: SHA-1, 6 digits and 30 second steps
+type TOTPService struct {
+	issuer string
+	skew   int
+}
+
+// NewTOTPService creates a TOTPService accepting codes up to skew steps before or after the current one
+func NewTOTPService(issuer string, skew int) *TOTPService {
+	return &TOTPService{issuer: issuer, skew: skew}
+}
+
+// GenerateSecret returns a new 160-bit secret
+func (s *TOTPService) GenerateSecret() (string, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// URI returns the otpauth:// URI of a secret
+func (s *TOTPService) URI(secret string, account string) string {
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {s.issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	label := url.PathEscape(s.issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Validate checks a code against the steps around now
+func (s *TOTPService) Validate(secret string, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for offset := -int64(s.skew); offset <= int64(s.skew); offset++ {
+		step := current + offset
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode computes the HOTP code (RFC 4226) of a time step
+func totpCode(key []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
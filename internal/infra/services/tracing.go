@@ -0,0 +1,6 @@
+package services
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts the spans of services worth timing on their own, such as token parsing
+var tracer = otel.Tracer("github.com/jdashel/posts-api/internal/infra/services")
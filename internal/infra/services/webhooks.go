@@ -0,0 +1,375 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// Webhook delivery headers
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 10 * time.Second
+	webhookMaxBackoff   = time.Hour
+	webhookPollInterval = 5 * time.Second
+	webhookLease        = time.Minute // Renewed before each attempt, must exceed the client timeout
+	webhookBatchSize    = 50
+	webhookQueueSize    = 1024
+)
+
+// errForbiddenTarget is returned for webhook URLs reaching the server's own network
+var errForbiddenTarget = fmt.Errorf("%w: webhook url must not resolve to a loopback, link-local or private address",
+	models.ErrInvalidInput)
+
+// WebhookDispatcher signs and delivers events to webhook subscribers, retrying failures with exponential backoff
+type WebhookDispatcher struct {
+	repository          interfaces.WebhooksRepository
+	uuidService         interfaces.UUIDService
+	client              *http.Client
+	allowPrivateTargets bool
+	events              chan models.SocketMessage
+	wake                chan struct{}
+	stop                chan struct{}
+	stopped             chan struct{}
+	stopOnce            sync.Once
+	logger              *slog.Logger
+}
+
+// NewWebhookDispatcher starts a dispatcher. Unless allowPrivateTargets is set, deliveries never
+// connect to loopback, link-local or private addresses, whatever the webhook host resolves to
+// when it is dialed.
+func NewWebhookDispatcher(repository interfaces.WebhooksRepository, uuidService interfaces.UUIDService, logger *slog.Logger,
+	allowPrivateTargets bool) *WebhookDispatcher {
+	dispatcher := &WebhookDispatcher{
+		repository:          repository,
+		uuidService:         uuidService,
+		allowPrivateTargets: allowPrivateTargets,
+		logger:              logger.With("component", "webhooks"),
+		events:              make(chan models.SocketMessage, webhookQueueSize),
+		wake:                make(chan struct{}, 1),
+		stop:                make(chan struct{}),
+		stopped:             make(chan struct{}),
+	}
+
+	// Addresses are checked once resolved, so DNS rebinding and redirects cannot reach them either
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: dispatcher.checkDial}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	dispatcher.client = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+
+	go dispatcher.Run()
+
+	return dispatcher
+}
+
+// SignWebhookPayload computes the hex encoded HMAC-SHA256 signature of a delivery
+// over "<timestamp>.<body>", sent as "sha256=<signature>"
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch queues the message for record, events are recorded in the order they were dispatched
+func (d *WebhookDispatcher) Dispatch(message models.SocketMessage) {
+	select {
+	case <-d.stop:
+		d.logger.Error("event dispatched after stopping, dropped", "event", message.Type)
+		return
+	default:
+	}
+
+	select {
+	case d.events <- message:
+	default:
+		d.logger.Error("event queue is full, dropped", "event", message.Type)
+	}
+}
+
+// record records a pending delivery for every webhook subscribed to the message type
+func (d *WebhookDispatcher) record(message models.SocketMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		d.logger.Error("failed to encode event", "event", message.Type, "error", err)
+		return
+	}
+
+	webhooks, err := d.repository.FindSubscribed(ctx, message.Type, message.OwnerID())
+	if err != nil {
+		d.logger.Error("failed to find subscribers", "event", message.Type, "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, webhook := range webhooks {
+		id, err := d.uuidService.GenerateID(ctx)
+		if err != nil {
+			d.logger.Error("failed to generate delivery id", "error", err)
+			continue
+		}
+
+		delivery := &models.WebhookDelivery{
+			ID:            id,
+			WebhookID:     webhook.ID,
+			Event:         message.Type,
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := d.repository.CreateDelivery(ctx, delivery); err != nil {
+			d.logger.Error("failed to record delivery", "webhook_id", webhook.ID, "error", err)
+		}
+	}
+
+	if len(webhooks) > 0 {
+		d.Wake()
+	}
+}
+
+// CheckURL resolves the host of a webhook URL and rejects it when any of its addresses is one
+// deliveries may not reach
+func (d *WebhookDispatcher) CheckURL(ctx context.Context, rawURL string) error {
+	if d.allowPrivateTargets {
+		return nil
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: invalid webhook url", models.ErrInvalidInput)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, target.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: webhook host %q cannot be resolved", models.ErrInvalidInput, target.Hostname())
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return errForbiddenTarget
+		}
+	}
+	return nil
+}
+
+// checkDial refuses connections to addresses deliveries may not reach
+func (d *WebhookDispatcher) checkDial(network string, address string, _ syscall.RawConn) error {
+	if d.allowPrivateTargets {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return errForbiddenTarget
+	}
+	return nil
+}
+
+// publicIP tells whether ip is outside the loopback, private, link-local and unspecified ranges
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsUnspecified() && !ip.IsMulticast() && !sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, private in practice
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Wake triggers an immediate pass over due deliveries
+func (d *WebhookDispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run records dispatched events, and delivers due deliveries whenever woken and periodically to
+// pick up retries, until stopped
+func (d *WebhookDispatcher) Run() {
+	defer close(d.stopped)
+
+	recorded := make(chan struct{})
+	go func() {
+		defer close(recorded)
+		d.recordEvents()
+	}()
+	defer func() { <-recorded }()
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.wake:
+		case <-ticker.C:
+		case <-d.stop:
+			return
+		}
+		d.deliverDue()
+	}
+}
+
+// recordEvents records the queued events one at a time, and those still queued once stopped
+func (d *WebhookDispatcher) recordEvents() {
+	for {
+		select {
+		case message := <-d.events:
+			d.record(message)
+		case <-d.stop:
+			for {
+				select {
+				case message := <-d.events:
+					d.record(message)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stop stops delivering once the delivery in progress is done, and records the events still
+// queued. Deliveries claimed but not attempted yet are picked up again once their lease expires.
+// It returns with the error of ctx when ctx is done first.
+func (d *WebhookDispatcher) Stop(ctx context.Context) error {
+	d.stopOnce.Do(func() { close(d.stop) })
+
+	select {
+	case <-d.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *WebhookDispatcher) deliverDue() {
+	for {
+		now := time.Now().UTC()
+		deliveries, err := d.repository.ClaimDueDeliveries(context.Background(), now, now.Add(webhookLease), webhookBatchSize)
+		if err != nil {
+			d.logger.Error("failed to claim deliveries", "error", err)
+			return
+		}
+
+		for _, delivery := range deliveries {
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+
+			// The batch may take longer than a lease, each delivery is leased again before its attempt, unless
+			// its lease ended and another dispatcher claimed it meanwhile
+			now := time.Now().UTC()
+			renewed, err := d.repository.RenewDeliveryLease(context.Background(), delivery.ID, *delivery.NextAttemptAt,
+				now.Add(webhookLease))
+			if err != nil {
+				d.logger.Error("failed to renew delivery lease", "delivery_id", delivery.ID, "error", err)
+				continue
+			}
+			if !renewed {
+				continue
+			}
+			d.attempt(delivery)
+		}
+
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// attempt sends a delivery once and schedules a retry or dead-letters it on failure
+func (d *WebhookDispatcher) attempt(delivery *models.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookLease)
+	defer cancel()
+
+	delivery.Attempts++
+	status, err := d.send(ctx, delivery)
+	delivery.ResponseStatus = status
+
+	now := time.Now().UTC()
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(webhookBackoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := d.repository.UpdateDelivery(ctx, delivery); err != nil {
+		d.logger.Error("failed to update delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	if !delivery.Webhook.Active {
+		return 0, fmt.Errorf("webhook is disabled")
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, delivery.Event)
+	request.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	request.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(delivery.Webhook.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected response status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// webhookBackoff returns the delay before the next attempt, doubling after every failure
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff << (attempts - 1)
+	if backoff <= 0 || backoff > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return backoff
+}
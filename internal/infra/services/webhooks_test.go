@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jdashel/posts-api/internal/domain/interfaces"
+	"github.com/jdashel/posts-api/internal/domain/models"
+)
+
+// webhooksRepository keeps deliveries in memory, the methods the dispatcher does not use panic
+type webhooksRepository struct {
+	interfaces.WebhooksRepository
+
+	mu         sync.Mutex
+	webhooks   []*models.Webhook
+	deliveries []*models.WebhookDelivery
+	updates    []models.WebhookDelivery
+}
+
+func (r *webhooksRepository) FindSubscribed(_ context.Context, event string, ownerId string) ([]*models.Webhook, error) {
+	subscribed := []*models.Webhook{}
+	for _, webhook := range r.webhooks {
+		if webhook.Active && webhook.OwnerID == ownerId && webhook.Subscribes(event) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	return subscribed, nil
+}
+
+func (r *webhooksRepository) CreateDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = append(r.deliveries, delivery)
+	return nil
+}
+
+func (r *webhooksRepository) ClaimDueDeliveries(context.Context, time.Time, time.Time, int) ([]*models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (r *webhooksRepository) UpdateDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates = append(r.updates, *delivery)
+	return nil
+}
+
+type sequentialIDs struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (s *sequentialIDs) GenerateID(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	return "delivery-" + strconv.Itoa(s.next), nil
+}
+
+// newTestDispatcher returns a stopped dispatcher allowed to reach the local test receiver, so
+// the tests drive its attempts themselves
+func newTestDispatcher(t *testing.T, repository interfaces.WebhooksRepository) *WebhookDispatcher {
+	t.Helper()
+	dispatcher := NewWebhookDispatcher(repository, &sequentialIDs{}, slog.New(slog.NewTextHandler(io.Discard, nil)), true)
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return dispatcher
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"type":"post_created"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignWebhookPayload("secret", "1700000000", body); got != want {
+		t.Errorf("SignWebhookPayload() = %s, want %s", got, want)
+	}
+	if got := SignWebhookPayload("other", "1700000000", body); got == want {
+		t.Error("SignWebhookPayload() does not depend on the secret")
+	}
+}
+
+func TestWebhookDeliveryIsSignedAndRetried(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(WebhookTimestampHeader)
+		if r.Header.Get(WebhookSignatureHeader) != "sha256="+SignWebhookPayload("secret", timestamp, body) {
+			t.Errorf("signature %q does not match the body", r.Header.Get(WebhookSignatureHeader))
+		}
+		if r.Header.Get(WebhookEventHeader) != models.EventPostCreated || r.Header.Get(WebhookDeliveryHeader) != "delivery-1" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	repository := &webhooksRepository{}
+	dispatcher := newTestDispatcher(t, repository)
+	delivery := &models.WebhookDelivery{
+		ID:      "delivery-1",
+		Event:   models.EventPostCreated,
+		Payload: []byte(`{"type":"post_created"}`),
+		Status:  models.DeliveryPending,
+		Webhook: &models.Webhook{URL: receiver.URL, Secret: "secret", Active: true},
+	}
+
+	dispatcher.attempt(delivery)
+	failed := repository.updates[0]
+	if failed.Status != models.DeliveryPending || failed.ResponseStatus != http.StatusInternalServerError || failed.LastError == "" {
+		t.Fatalf("failed attempt recorded as %+v", failed)
+	}
+	if failed.NextAttemptAt == nil || time.Until(*failed.NextAttemptAt) < webhookBaseBackoff-time.Second {
+		t.Errorf("retry scheduled at %v, want after the base backoff", failed.NextAttemptAt)
+	}
+
+	dispatcher.attempt(delivery)
+	succeeded := repository.updates[1]
+	if succeeded.Status != models.DeliverySucceeded || succeeded.Attempts != 2 || succeeded.DeliveredAt == nil {
+		t.Errorf("second attempt recorded as %+v", succeeded)
+	}
+}
+
+func TestWebhookDeliveryIsDeadLettered(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+
+	repository := &webhooksRepository{}
+	dispatcher := newTestDispatcher(t, repository)
+	delivery := &models.WebhookDelivery{
+		ID:       "delivery-1",
+		Attempts: webhookMaxAttempts - 1,
+		Status:   models.DeliveryPending,
+		Webhook:  &models.Webhook{URL: receiver.URL, Secret: "secret", Active: true},
+	}
+
+	dispatcher.attempt(delivery)
+	if dead := repository.updates[0]; dead.Status != models.DeliveryDead || dead.NextAttemptAt != nil {
+		t.Errorf("last attempt recorded as %+v", dead)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, webhookBaseBackoff},
+		{2, 2 * webhookBaseBackoff},
+		{4, 8 * webhookBaseBackoff},
+		{20, webhookMaxBackoff},
+		{70, webhookMaxBackoff},
+	}
+	for _, test := range tests {
+		if got := webhookBackoff(test.attempts); got != test.want {
+			t.Errorf("webhookBackoff(%d) = %s, want %s", test.attempts, got, test.want)
+		}
+	}
+}
+
+func TestDispatchOnlyReachesTheOwnersWebhooks(t *testing.T) {
+	repository := &webhooksRepository{webhooks: []*models.Webhook{
+		{ID: "own", OwnerID: "author", Events: []string{models.WebhookAllEvents}, Active: true},
+		{ID: "other", OwnerID: "someone-else", Events: []string{models.WebhookAllEvents}, Active: true},
+	}}
+	dispatcher := newTestDispatcher(t, repository)
+
+	dispatcher.record(models.NewPostCreatedMessage(&models.Post{ID: "post", AuthorID: "author"}, time.Now()))
+
+	if len(repository.deliveries) != 1 || repository.deliveries[0].WebhookID != "own" {
+		t.Errorf("deliveries = %+v, want one for the author's webhook", repository.deliveries)
+	}
+}
+
+func TestDispatchRecordsEventsInOrderBeforeStopping(t *testing.T) {
+	repository := &webhooksRepository{webhooks: []*models.Webhook{
+		{ID: "own", OwnerID: "author", Events: []string{models.WebhookAllEvents}, Active: true},
+	}}
+	dispatcher := NewWebhookDispatcher(repository, &sequentialIDs{}, slog.New(slog.NewTextHandler(io.Discard, nil)), true)
+
+	post := &models.Post{ID: "post", AuthorID: "author"}
+	dispatcher.Dispatch(models.NewPostCreatedMessage(post, time.Now()))
+	dispatcher.Dispatch(models.NewPostUpdatedMessage(post, time.Now()))
+	dispatcher.Dispatch(models.NewPostDeletedMessage(post.ID, post.AuthorID, time.Now()))
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	events := []string{}
+	for _, delivery := range repository.deliveries {
+		events = append(events, delivery.Event)
+	}
+	if len(events) != 3 || events[0] != models.EventPostCreated || events[1] != models.EventPostUpdated ||
+		events[2] != models.EventPostDeleted {
+		t.Errorf("recorded %v, want the events in the order they were dispatched", events)
+	}
+
+	dispatcher.Dispatch(models.NewPostCreatedMessage(post, time.Now()))
+	if len(repository.deliveries) != 3 {
+		t.Error("an event dispatched after stopping was recorded")
+	}
+}
+
+func TestCheckURLRejectsPrivateTargets(t *testing.T) {
+	dispatcher := &WebhookDispatcher{}
+	for _, target := range []string{"http://127.0.0.1/hook", "http://localhost:8080", "http://10.0.0.1", "http://[::1]/",
+		"http://169.254.169.254/latest/meta-data"} {
+		if err := dispatcher.CheckURL(context.Background(), target); err == nil {
+			t.Errorf("CheckURL(%q) accepted a private target", target)
+		}
+	}
+	if err := dispatcher.CheckURL(context.Background(), "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("CheckURL() rejected a public address: %v", err)
+	}
+}